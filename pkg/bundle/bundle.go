@@ -0,0 +1,189 @@
+// Package bundle loads the OLM bundle generated for a sample by `make bundle` so it
+// can be edited and written back, or checked for consistency
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Bundle is a bundle directory with manifests/ and metadata/annotations.yaml,
+// and the bundle.Dockerfile that builds it
+type Bundle struct {
+	dir string
+
+	CSV         *ClusterServiceVersion
+	CRDs        []*CustomResourceDefinition
+	Manifests   []*Manifest
+	Annotations *Annotations
+	// Dockerfile is nil if the bundle has no bundle.Dockerfile
+	Dockerfile *Dockerfile
+}
+
+// LoadSample loads the bundle generated in the bundle directory of the Sample
+func LoadSample(sample samples.Sample) (*Bundle, error) {
+	return Load(filepath.Join(sample.CommandContext().Dir(), sample.Name(), "bundle"))
+}
+
+// Load loads the bundle in the directory. The bundle.Dockerfile is looked up next to
+// the directory, where operator-sdk generates it, and then inside it.
+func Load(dir string) (*Bundle, error) {
+	b := &Bundle{dir: dir}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "manifests"))
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading the bundle manifests: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		m, err := LoadManifest(filepath.Join(dir, "manifests", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		switch m.Kind() {
+		case "ClusterServiceVersion":
+			if b.CSV != nil {
+				return nil, fmt.Errorf("bundle %s has more than one ClusterServiceVersion: %s and %s", dir, b.CSV.Path(), m.Path())
+			}
+			b.CSV = &ClusterServiceVersion{Manifest: m}
+		case "CustomResourceDefinition":
+			b.CRDs = append(b.CRDs, &CustomResourceDefinition{Manifest: m})
+		default:
+			b.Manifests = append(b.Manifests, m)
+		}
+	}
+
+	if b.CSV == nil {
+		return nil, fmt.Errorf("bundle %s has no ClusterServiceVersion", dir)
+	}
+
+	b.Annotations, err = loadAnnotations(filepath.Join(dir, "metadata", "annotations.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range []string{
+		filepath.Join(filepath.Dir(dir), "bundle.Dockerfile"),
+		filepath.Join(dir, "bundle.Dockerfile"),
+	} {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		b.Dockerfile, err = loadDockerfile(path)
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	return b, nil
+}
+
+// Dir returns the bundle directory
+func (b *Bundle) Dir() string {
+	return b.dir
+}
+
+// SetAnnotation sets the annotation in annotations.yaml and the matching bundle.Dockerfile label
+func (b *Bundle) SetAnnotation(key string, value string) {
+	b.Annotations.Set(key, value)
+	if b.Dockerfile != nil {
+		b.Dockerfile.SetLabel(key, value)
+	}
+}
+
+// RemoveAnnotations removes the annotations from annotations.yaml and the matching
+// bundle.Dockerfile labels
+func (b *Bundle) RemoveAnnotations(keys ...string) {
+	b.Annotations.Remove(keys...)
+	if b.Dockerfile != nil {
+		b.Dockerfile.RemoveLabels(keys...)
+	}
+}
+
+// StripGeneratorMetadata removes the annotations that record the operator-sdk version and
+// project layout used to generate the bundle, so bundles can be compared across versions
+func (b *Bundle) StripGeneratorMetadata() {
+	b.RemoveAnnotations(MetricsMediaTypeAnnotation, MetricsBuilderAnnotation, MetricsProjectLayoutAnnotation)
+	b.CSV.RemoveAnnotations(BuilderObjectAnnotation, ProjectLayoutObjectAnnotation)
+}
+
+// Write writes the files of the bundle that were changed back
+func (b *Bundle) Write() error {
+	writers := []interface{ Write() error }{b.CSV, b.Annotations}
+	for _, crd := range b.CRDs {
+		writers = append(writers, crd)
+	}
+	for _, m := range b.Manifests {
+		writers = append(writers, m)
+	}
+	if b.Dockerfile != nil {
+		writers = append(writers, b.Dockerfile)
+	}
+
+	for _, w := range writers {
+		if err := w.Write(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the bundle is consistent: the required annotations are set, the
+// bundle.Dockerfile labels match annotations.yaml and every CRD owned by the
+// ClusterServiceVersion is in the bundle. It does not replace `operator-sdk bundle validate`.
+func (b *Bundle) Validate() error {
+	var errs []error
+
+	for _, key := range []string{MediaTypeAnnotation, ManifestsAnnotation, MetadataAnnotation, PackageAnnotation, ChannelsAnnotation} {
+		if _, ok := b.Annotations.Get(key); !ok {
+			errs = append(errs, fmt.Errorf("annotation %s is missing from %s", key, b.Annotations.path))
+		}
+	}
+
+	if b.Dockerfile != nil {
+		labels := b.Dockerfile.Labels()
+		annotations := b.Annotations.All()
+		for _, key := range sortedKeys(annotations) {
+			if label, ok := labels[key]; !ok {
+				errs = append(errs, fmt.Errorf("annotation %s has no matching label in %s", key, b.Dockerfile.path))
+			} else if label != annotations[key] {
+				errs = append(errs, fmt.Errorf("label %s in %s is %q, but the annotation is %q", key, b.Dockerfile.path, label, annotations[key]))
+			}
+		}
+		for _, key := range sortedKeys(labels) {
+			if _, ok := annotations[key]; !ok && strings.HasPrefix(key, "operators.operatorframework.io") {
+				errs = append(errs, fmt.Errorf("label %s in %s is missing from %s", key, b.Dockerfile.path, b.Annotations.path))
+			}
+		}
+	}
+
+	crds := make(map[string]bool, len(b.CRDs))
+	for _, crd := range b.CRDs {
+		crds[crd.Name()] = true
+	}
+	owned := b.CSV.OwnedCRDs()
+	sort.Strings(owned)
+	for _, name := range owned {
+		if !crds[name] {
+			errs = append(errs, fmt.Errorf("CRD %s is owned by the ClusterServiceVersion but is not in the bundle", name))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,217 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAnnotations = `annotations:
+  # Core bundle annotations.
+  operators.operatorframework.io.bundle.mediatype.v1: registry+v1
+  operators.operatorframework.io.bundle.manifests.v1: manifests/
+  operators.operatorframework.io.bundle.metadata.v1: metadata/
+  operators.operatorframework.io.bundle.package.v1: memcached-operator
+  operators.operatorframework.io.bundle.channels.v1: alpha
+  operators.operatorframework.io.metrics.builder: operator-sdk-v1.22.0
+  operators.operatorframework.io.metrics.mediatype.v1: metrics+v1
+  operators.operatorframework.io.metrics.project_layout: go.kubebuilder.io/v3
+`
+
+const testDockerfile = `FROM scratch
+
+# Core bundle labels.
+LABEL operators.operatorframework.io.bundle.mediatype.v1=registry+v1
+LABEL operators.operatorframework.io.bundle.manifests.v1=manifests/
+LABEL operators.operatorframework.io.bundle.metadata.v1=metadata/
+LABEL operators.operatorframework.io.bundle.package.v1=memcached-operator
+LABEL operators.operatorframework.io.bundle.channels.v1=alpha
+LABEL operators.operatorframework.io.metrics.builder=operator-sdk-v1.22.0
+LABEL operators.operatorframework.io.metrics.mediatype.v1=metrics+v1
+LABEL operators.operatorframework.io.metrics.project_layout=go.kubebuilder.io/v3
+
+COPY bundle/manifests /manifests/
+COPY bundle/metadata /metadata/
+`
+
+const testCSV = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  annotations:
+    operators.operatorframework.io/builder: operator-sdk-v1.22.0
+    operators.operatorframework.io/project_layout: go.kubebuilder.io/v3
+  name: memcached-operator.v0.0.1
+spec:
+  customresourcedefinitions:
+    owned:
+    - kind: Memcached
+      name: memcacheds.cache.example.com
+      version: v1alpha1
+  version: 0.0.1
+`
+
+const testCRD = `# generated by controller-gen
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: memcacheds.cache.example.com
+spec:
+  group: cache.example.com
+  names:
+    kind: Memcached
+    plural: memcacheds
+`
+
+func TestBundleWrite(t *testing.T) {
+	dir := writeTestBundle(t)
+
+	b, err := Load(filepath.Join(dir, "bundle"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	b.StripGeneratorMetadata()
+	b.SetAnnotation(DefaultChannelAnnotation, "alpha")
+	if err := b.Write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	annotations := readTestFile(t, filepath.Join(dir, "bundle", "metadata", "annotations.yaml"))
+	if !strings.Contains(annotations, "# Core bundle annotations.") {
+		t.Errorf("expected the comment in annotations.yaml to be kept, got:\n%s", annotations)
+	}
+	if strings.Contains(annotations, MetricsBuilderAnnotation) {
+		t.Errorf("expected %s to be removed, got:\n%s", MetricsBuilderAnnotation, annotations)
+	}
+	if !strings.HasSuffix(annotations, "  "+DefaultChannelAnnotation+": alpha\n") {
+		t.Errorf("expected %s to be added after the existing annotations, got:\n%s", DefaultChannelAnnotation, annotations)
+	}
+
+	dockerfile := readTestFile(t, filepath.Join(dir, "bundle.Dockerfile"))
+	if !strings.Contains(dockerfile, "LABEL "+DefaultChannelAnnotation+"=alpha") || strings.Contains(dockerfile, MetricsBuilderAnnotation) {
+		t.Errorf("expected the bundle.Dockerfile labels to match the annotations, got:\n%s", dockerfile)
+	}
+
+	if crd := readTestFile(t, b.CRDs[0].Path()); crd != testCRD {
+		t.Errorf("expected the unchanged CRD not to be rewritten, got:\n%s", crd)
+	}
+
+	reloaded, err := Load(filepath.Join(dir, "bundle"))
+	if err != nil {
+		t.Fatalf("unexpected error reloading the bundle: %v", err)
+	}
+	if err := reloaded.Validate(); err != nil {
+		t.Errorf("unexpected validation error after writing: %v", err)
+	}
+	if _, ok := reloaded.CSV.Object().GetAnnotations()[BuilderObjectAnnotation]; ok {
+		t.Errorf("expected %s to be removed from the ClusterServiceVersion", BuilderObjectAnnotation)
+	}
+}
+
+func TestBundleWriteUnchanged(t *testing.T) {
+	dir := writeTestBundle(t)
+
+	b, err := Load(filepath.Join(dir, "bundle"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Setting the existing value and removing a missing annotation are not changes
+	b.SetAnnotation(ChannelsAnnotation, "alpha")
+	b.RemoveAnnotations("missing")
+
+	past := time.Now().Add(-time.Hour)
+	paths := []string{
+		b.CSV.Path(),
+		b.CRDs[0].Path(),
+		filepath.Join(dir, "bundle", "metadata", "annotations.yaml"),
+		filepath.Join(dir, "bundle.Dockerfile"),
+	}
+	for _, path := range paths {
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.Write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(past) {
+			t.Errorf("expected unchanged %s not to be written", filepath.Base(path))
+		}
+	}
+}
+
+func TestBundleValidate(t *testing.T) {
+	dir := writeTestBundle(t)
+	if err := os.Remove(filepath.Join(dir, "bundle", "manifests", "cache.example.com_memcacheds.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Load(filepath.Join(dir, "bundle"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b.Annotations.Remove(PackageAnnotation)
+	b.Dockerfile.SetLabel(ChannelsAnnotation, "beta")
+
+	err = b.Validate()
+	if err == nil {
+		t.Fatalf("expected a validation error")
+	}
+	for _, expected := range []string{
+		"annotation " + PackageAnnotation + " is missing",
+		"label " + ChannelsAnnotation,
+		"CRD memcacheds.cache.example.com is owned by the ClusterServiceVersion but is not in the bundle",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain %q, got: %v", expected, err)
+		}
+	}
+}
+
+// writeTestBundle writes a bundle generated by operator-sdk to a temporary directory,
+// with the bundle.Dockerfile next to the bundle directory
+func writeTestBundle(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		filepath.Join("bundle", "manifests", "memcached-operator.clusterserviceversion.yaml"): testCSV,
+		filepath.Join("bundle", "manifests", "cache.example.com_memcacheds.yaml"):             testCRD,
+		filepath.Join("bundle", "metadata", "annotations.yaml"):                               testAnnotations,
+		"bundle.Dockerfile": testDockerfile,
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,265 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest is an object in the manifests directory of a bundle
+type Manifest struct {
+	path string
+	obj  *unstructured.Unstructured
+	// written is the object as it is in the file, to skip writing unchanged objects
+	written map[string]interface{}
+}
+
+// LoadManifest loads the object in the YAML file at path
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading %q: %w", path, err)
+	}
+
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding %q: %w", path, err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(j); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding %q: %w", path, err)
+	}
+
+	return &Manifest{path: path, obj: obj, written: obj.DeepCopy().Object}, nil
+}
+
+// Path returns the file the object was loaded from
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// Object returns the underlying object, which can be edited directly for fields that have no helper
+func (m *Manifest) Object() *unstructured.Unstructured {
+	return m.obj
+}
+
+func (m *Manifest) Kind() string {
+	return m.obj.GetKind()
+}
+
+func (m *Manifest) Name() string {
+	return m.obj.GetName()
+}
+
+// SetLabel sets the label in metadata.labels
+func (m *Manifest) SetLabel(key string, value string) {
+	labels := m.obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[key] = value
+	m.obj.SetLabels(labels)
+}
+
+// RemoveLabels removes the labels from metadata.labels
+func (m *Manifest) RemoveLabels(keys ...string) {
+	labels := m.obj.GetLabels()
+	for _, key := range keys {
+		delete(labels, key)
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	m.obj.SetLabels(labels)
+}
+
+// SetAnnotation sets the annotation in metadata.annotations
+func (m *Manifest) SetAnnotation(key string, value string) {
+	annotations := m.obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+	m.obj.SetAnnotations(annotations)
+}
+
+// RemoveAnnotations removes the annotations from metadata.annotations
+func (m *Manifest) RemoveAnnotations(keys ...string) {
+	annotations := m.obj.GetAnnotations()
+	for _, key := range keys {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	m.obj.SetAnnotations(annotations)
+}
+
+// SetField sets the field at the path from the root of the object, i.e SetField("alpha", "spec", "maturity")
+func (m *Manifest) SetField(value interface{}, fields ...string) error {
+	if err := unstructured.SetNestedField(m.obj.Object, value, fields...); err != nil {
+		return fmt.Errorf("encountered an error when setting %v in %s: %w", fields, m.path, err)
+	}
+	return nil
+}
+
+// RemoveField removes the field at the path from the root of the object
+func (m *Manifest) RemoveField(fields ...string) {
+	unstructured.RemoveNestedField(m.obj.Object, fields...)
+}
+
+// Write writes the object back to the file it was loaded from if it was changed
+func (m *Manifest) Write() error {
+	if reflect.DeepEqual(m.obj.Object, m.written) {
+		return nil
+	}
+
+	b, err := yaml.Marshal(m.obj.Object)
+	if err != nil {
+		return fmt.Errorf("encountered an error when encoding %s: %w", m.path, err)
+	}
+
+	if err := os.WriteFile(m.path, b, 0644); err != nil {
+		return fmt.Errorf("encountered an error when writing %q: %w", m.path, err)
+	}
+
+	m.written = m.obj.DeepCopy().Object
+	return nil
+}
+
+// ClusterServiceVersion is the ClusterServiceVersion of a bundle
+type ClusterServiceVersion struct {
+	*Manifest
+}
+
+// LoadClusterServiceVersion loads a ClusterServiceVersion outside of a bundle,
+// i.e the base in config/manifests/bases
+func LoadClusterServiceVersion(path string) (*ClusterServiceVersion, error) {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Kind() != "ClusterServiceVersion" {
+		return nil, fmt.Errorf("%s is a %s, not a ClusterServiceVersion", path, m.Kind())
+	}
+
+	return &ClusterServiceVersion{Manifest: m}, nil
+}
+
+// Version returns spec.version
+func (csv *ClusterServiceVersion) Version() string {
+	version, _, _ := unstructured.NestedString(csv.obj.Object, "spec", "version")
+	return version
+}
+
+// SetVersion sets spec.version and, following the operator-sdk naming convention,
+// the version suffix of the name, i.e memcached-operator.v0.0.2
+func (csv *ClusterServiceVersion) SetVersion(version string) error {
+	if old := ".v" + csv.Version(); old != ".v" && strings.HasSuffix(csv.Name(), old) {
+		csv.obj.SetName(strings.TrimSuffix(csv.Name(), old) + ".v" + version)
+	}
+
+	return csv.SetField(version, "spec", "version")
+}
+
+// Replaces returns spec.replaces, the name of the ClusterServiceVersion this one upgrades
+func (csv *ClusterServiceVersion) Replaces() string {
+	replaces, _, _ := unstructured.NestedString(csv.obj.Object, "spec", "replaces")
+	return replaces
+}
+
+// SetReplaces sets spec.replaces. An empty name removes it.
+func (csv *ClusterServiceVersion) SetReplaces(name string) error {
+	if name == "" {
+		csv.RemoveField("spec", "replaces")
+		return nil
+	}
+
+	return csv.SetField(name, "spec", "replaces")
+}
+
+// OwnedCRDs returns the names of the CRDs listed in spec.customresourcedefinitions.owned
+func (csv *ClusterServiceVersion) OwnedCRDs() []string {
+	owned, _, _ := unstructured.NestedSlice(csv.obj.Object, "spec", "customresourcedefinitions", "owned")
+
+	var names []string
+	for _, o := range owned {
+		if m, ok := o.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// SetContainerImage sets the image of the named container in every install strategy
+// Deployment that has it, and returns an error if there is none
+func (csv *ClusterServiceVersion) SetContainerImage(container string, image string) error {
+	deployments, _, _ := unstructured.NestedSlice(csv.obj.Object, "spec", "install", "spec", "deployments")
+
+	found := false
+	for _, d := range deployments {
+		deployment, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		containers, _, _ := unstructured.NestedSlice(deployment, "spec", "template", "spec", "containers")
+		for _, c := range containers {
+			if c, ok := c.(map[string]interface{}); ok && c["name"] == container {
+				c["image"] = image
+				found = true
+			}
+		}
+		if err := unstructured.SetNestedSlice(deployment, containers, "spec", "template", "spec", "containers"); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("could not find container %q in the install strategy of %s", container, csv.path)
+	}
+
+	return unstructured.SetNestedSlice(csv.obj.Object, deployments, "spec", "install", "spec", "deployments")
+}
+
+// CustomResourceDefinition is a CRD in a bundle
+type CustomResourceDefinition struct {
+	*Manifest
+}
+
+// Group returns spec.group
+func (crd *CustomResourceDefinition) Group() string {
+	group, _, _ := unstructured.NestedString(crd.obj.Object, "spec", "group")
+	return group
+}
+
+// ResourceKind returns spec.names.kind, the kind of the custom resources
+func (crd *CustomResourceDefinition) ResourceKind() string {
+	kind, _, _ := unstructured.NestedString(crd.obj.Object, "spec", "names", "kind")
+	return kind
+}
+
+// Versions returns the names of spec.versions
+func (crd *CustomResourceDefinition) Versions() []string {
+	versions, _, _ := unstructured.NestedSlice(crd.obj.Object, "spec", "versions")
+
+	var names []string
+	for _, v := range versions {
+		if m, ok := v.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,271 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Annotations in metadata/annotations.yaml, mirrored as labels in bundle.Dockerfile
+const (
+	MediaTypeAnnotation      = "operators.operatorframework.io.bundle.mediatype.v1"
+	ManifestsAnnotation      = "operators.operatorframework.io.bundle.manifests.v1"
+	MetadataAnnotation       = "operators.operatorframework.io.bundle.metadata.v1"
+	PackageAnnotation        = "operators.operatorframework.io.bundle.package.v1"
+	ChannelsAnnotation       = "operators.operatorframework.io.bundle.channels.v1"
+	DefaultChannelAnnotation = "operators.operatorframework.io.bundle.channel.default.v1"
+
+	// Metrics annotations record the operator-sdk version and project layout that generated the bundle
+	MetricsMediaTypeAnnotation     = "operators.operatorframework.io.metrics.mediatype.v1"
+	MetricsBuilderAnnotation       = "operators.operatorframework.io.metrics.builder"
+	MetricsProjectLayoutAnnotation = "operators.operatorframework.io.metrics.project_layout"
+)
+
+// Annotations on the ClusterServiceVersion recording the operator-sdk version and project layout
+const (
+	BuilderObjectAnnotation       = "operators.operatorframework.io/builder"
+	ProjectLayoutObjectAnnotation = "operators.operatorframework.io/project_layout"
+)
+
+// Annotations is metadata/annotations.yaml of a bundle. It is edited as a YAML node
+// tree, so comments and the order of the annotations are kept when it is written.
+type Annotations struct {
+	path    string
+	doc     yaml.Node
+	values  *yaml.Node
+	changed bool
+}
+
+func loadAnnotations(path string) (*Annotations, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading %q: %w", path, err)
+	}
+
+	a := &Annotations{path: path}
+	if err := yaml.Unmarshal(b, &a.doc); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding %q: %w", path, err)
+	}
+	if a.doc.Kind == 0 {
+		a.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	root := a.doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a map", path)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "annotations" {
+			continue
+		}
+		if root.Content[i+1].Tag == "!!null" {
+			root.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode}
+		}
+		a.values = root.Content[i+1]
+	}
+	if a.values == nil {
+		a.values = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "annotations"}, a.values)
+	}
+	if a.values.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("annotations in %s is not a map", path)
+	}
+
+	return a, nil
+}
+
+// Get returns the value of the annotation and whether it is set
+func (a *Annotations) Get(key string) (string, bool) {
+	if i := a.index(key); i >= 0 {
+		return a.values.Content[i+1].Value, true
+	}
+	return "", false
+}
+
+// Set sets the annotation, keeping its position if it exists or adding it after the last annotation
+func (a *Annotations) Set(key string, value string) {
+	if i := a.index(key); i >= 0 {
+		if a.values.Content[i+1].Value != value {
+			a.values.Content[i+1].SetString(value)
+			a.changed = true
+		}
+		return
+	}
+
+	keyNode, valueNode := &yaml.Node{}, &yaml.Node{}
+	keyNode.SetString(key)
+	valueNode.SetString(value)
+	a.values.Content = append(a.values.Content, keyNode, valueNode)
+	a.changed = true
+}
+
+// Remove removes the annotations for the keys, keys that are not set are ignored
+func (a *Annotations) Remove(keys ...string) {
+	for _, key := range keys {
+		if i := a.index(key); i >= 0 {
+			a.values.Content = append(a.values.Content[:i], a.values.Content[i+2:]...)
+			a.changed = true
+		}
+	}
+}
+
+// All returns a copy of the annotations
+func (a *Annotations) All() map[string]string {
+	all := make(map[string]string, len(a.values.Content)/2)
+	for i := 0; i+1 < len(a.values.Content); i += 2 {
+		all[a.values.Content[i].Value] = a.values.Content[i+1].Value
+	}
+	return all
+}
+
+// Write writes the annotations back to annotations.yaml if they were changed
+func (a *Annotations) Write() error {
+	if !a.changed {
+		return nil
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&a.doc); err != nil {
+		return fmt.Errorf("encountered an error when encoding %s: %w", a.path, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encountered an error when encoding %s: %w", a.path, err)
+	}
+
+	if err := os.WriteFile(a.path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("encountered an error when writing %q: %w", a.path, err)
+	}
+
+	a.changed = false
+	return nil
+}
+
+// index returns the index of the key node of the annotation, or -1
+func (a *Annotations) index(key string) int {
+	for i := 0; i+1 < len(a.values.Content); i += 2 {
+		if a.values.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Dockerfile is the bundle.Dockerfile of a bundle. Only `LABEL key=value` lines
+// with a single label are treated as labels, all other lines are kept as is.
+type Dockerfile struct {
+	path     string
+	lines    []string
+	original string
+}
+
+func loadDockerfile(path string) (*Dockerfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading %q: %w", path, err)
+	}
+
+	d := &Dockerfile{path: path, lines: strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")}
+	d.original = d.content()
+	return d, nil
+}
+
+// Labels returns the labels set with LABEL instructions
+func (d *Dockerfile) Labels() map[string]string {
+	labels := make(map[string]string)
+	for _, line := range d.lines {
+		if key, value, ok := parseLabel(line); ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// SetLabel replaces the LABEL instruction for the key, or adds one after the last LABEL
+func (d *Dockerfile) SetLabel(key string, value string) {
+	instruction := fmt.Sprintf("LABEL %s=%s", key, value)
+
+	last := -1
+	for i, line := range d.lines {
+		k, _, ok := parseLabel(line)
+		if !ok {
+			continue
+		}
+		if k == key {
+			d.lines[i] = instruction
+			return
+		}
+		last = i
+	}
+
+	if last < 0 {
+		d.lines = append(d.lines, instruction)
+		return
+	}
+	d.lines = append(d.lines[:last+1], append([]string{instruction}, d.lines[last+1:]...)...)
+}
+
+// RemoveLabels removes the LABEL instructions for the keys
+func (d *Dockerfile) RemoveLabels(keys ...string) {
+	remove := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	kept := d.lines[:0]
+	for _, line := range d.lines {
+		if key, _, ok := parseLabel(line); ok && remove[key] {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	d.lines = kept
+}
+
+// Write writes the Dockerfile back to its file if it was changed
+func (d *Dockerfile) Write() error {
+	content := d.content()
+	if content == d.original {
+		return nil
+	}
+
+	if err := os.WriteFile(d.path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("encountered an error when writing %q: %w", d.path, err)
+	}
+
+	d.original = content
+	return nil
+}
+
+func (d *Dockerfile) content() string {
+	return strings.Join(d.lines, "\n") + "\n"
+}
+
+func parseLabel(line string) (string, string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "LABEL") {
+		return "", "", false
+	}
+
+	kv := strings.SplitN(fields[1], "=", 2)
+	if len(kv) != 2 {
+		return "", "", false
+	}
+
+	return kv[0], strings.Trim(kv[1], `"`), true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/bundle"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// BundleValidation is the result of validating a bundle
+type BundleValidation struct {
+	Passed   bool
+	Errors   []string
+	Warnings []string
+}
+
+// Err returns an error describing the validation errors, if any. If failOnWarnings
+// is set validation warnings are also treated as errors.
+func (bv *BundleValidation) Err(failOnWarnings bool) error {
+	problems := append([]string{}, bv.Errors...)
+	if failOnWarnings {
+		problems = append(problems, bv.Warnings...)
+	}
+
+	if len(problems) == 0 && bv.Passed {
+		return nil
+	}
+
+	return fmt.Errorf("bundle validation failed:\n\t%s", strings.Join(problems, "\n\t"))
+}
+
+// bundleValidationOutput is the `operator-sdk bundle validate -o json-alpha1` output
+type bundleValidationOutput struct {
+	Passed  bool `json:"passed"`
+	Outputs []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"outputs"`
+}
+
+// ValidateBundle loads the bundle of the sample and checks it with bundle.Validate,
+// then runs `operator-sdk bundle validate` against it with the optional validators
+// of the given suites, defaulting to the operatorframework suite when none are
+// given, and returns the errors and warnings of both. An error is only returned if
+// the validation could not be run, use BundleValidation.Err or the BeValidBundle
+// matcher to check the outcome.
+func ValidateBundle(sample samples.Sample, suites ...string) (*BundleValidation, error) {
+	return ValidateBundleWithOptions(sample, WithSuites(suites...))
+}
+
+// ValidateBundleWithOptions is ValidateBundle configured with OLMOptions, the
+// validator suites are set with WithSuites
+func ValidateBundleWithOptions(sample samples.Sample, opts ...OLMOption) (*BundleValidation, error) {
+	oo := newOLMOptions(opts...)
+	suites := oo.suites
+	if len(suites) == 0 {
+		suites = []string{"operatorframework"}
+	}
+
+	args := []string{"bundle", "validate", "./bundle",
+		"--select-optional", fmt.Sprintf("suite in (%s)", strings.Join(suites, ",")),
+		"--output", "json-alpha1",
+	}
+	args = append(args, oo.extraArgs...)
+
+	cmd := exec.Command(oo.binary, args...)
+	// validate exits with an error when the bundle is invalid, so only
+	// report an error if the output could not be parsed
+	out, runErr := sample.CommandContext().Run(cmd, sample.Name())
+
+	bv, err := ParseBundleValidation(out)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when validating the bundle: %v, output: %s", runErr, string(out))
+	}
+
+	for _, err := range validateBundleFiles(sample) {
+		bv.Passed = false
+		bv.Errors = append(bv.Errors, err.Error())
+	}
+
+	return bv, nil
+}
+
+// validateBundleFiles returns the consistency errors of the bundle files that
+// `operator-sdk bundle validate` does not check, see bundle.Validate
+func validateBundleFiles(sample samples.Sample) []error {
+	b, err := bundle.LoadSample(sample)
+	if err != nil {
+		return []error{err}
+	}
+
+	err = b.Validate()
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		return agg.Errors()
+	}
+	if err != nil {
+		return []error{err}
+	}
+
+	return nil
+}
+
+// ParseBundleValidation parses the output of `operator-sdk bundle validate -o json-alpha1`
+func ParseBundleValidation(out []byte) (*BundleValidation, error) {
+	start := jsonDocumentStart(out)
+	if start < 0 {
+		return nil, fmt.Errorf("no bundle validation results found")
+	}
+
+	var output bundleValidationOutput
+	if err := json.NewDecoder(bytes.NewReader(out[start:])).Decode(&output); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding bundle validation results: %w", err)
+	}
+
+	bv := &BundleValidation{Passed: output.Passed}
+	for _, o := range output.Outputs {
+		switch o.Type {
+		case "error":
+			bv.Errors = append(bv.Errors, o.Message)
+		case "warning":
+			bv.Warnings = append(bv.Warnings, o.Message)
+		}
+	}
+
+	return bv, nil
+}
+
+// BeValidBundle succeeds if the actual *BundleValidation has no errors. If failOnWarnings
+// is set the validation must not have any warnings either.
+func BeValidBundle(failOnWarnings bool) types.GomegaMatcher {
+	return &beValidBundleMatcher{failOnWarnings: failOnWarnings}
+}
+
+type beValidBundleMatcher struct {
+	failOnWarnings bool
+}
+
+func (bvbm *beValidBundleMatcher) Match(actual interface{}) (bool, error) {
+	bv, ok := actual.(*BundleValidation)
+	if !ok {
+		return false, fmt.Errorf("BeValidBundle matcher expects a *BundleValidation. Got:\n%s", format.Object(actual, 1))
+	}
+
+	return bv.Err(bvbm.failOnWarnings) == nil, nil
+}
+
+func (bvbm *beValidBundleMatcher) FailureMessage(actual interface{}) string {
+	bv, _ := actual.(*BundleValidation)
+	return fmt.Sprintf("Expected the bundle to be valid, but %v", bv.Err(bvbm.failOnWarnings))
+}
+
+func (bvbm *beValidBundleMatcher) NegatedFailureMessage(actual interface{}) string {
+	return "Expected the bundle not to be valid, but it was"
+}
@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBundleValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    *BundleValidation
+		wantErr bool
+	}{
+		{
+			name: "passed without outputs",
+			out:  `{"passed": true, "outputs": null}`,
+			want: &BundleValidation{Passed: true},
+		},
+		{
+			name: "errors and warnings after log lines",
+			out: "time=\"2022-06-01T12:00:00Z\" level=debug msg=\"Found manifests directory\" name=bundle-dir\n" +
+				"time=\"2022-06-01T12:00:00Z\" level=debug msg=\"Validating bundle {memcached-operator.v0.0.1}\"\n" +
+				`{
+  "passed": false,
+  "outputs": [
+    {"type": "error", "message": "Error: Value : (memcached-operator.v0.0.1) csv.Spec.minKubeVersion is not informed"},
+    {"type": "warning", "message": "Warning: Value memcached-operator.v0.0.1: owned CRD \"memcacheds.cache.example.com\" has an empty description"},
+    {"type": "info", "message": "all validation tests have completed"}
+  ]
+}
+`,
+			want: &BundleValidation{
+				Errors:   []string{"Error: Value : (memcached-operator.v0.0.1) csv.Spec.minKubeVersion is not informed"},
+				Warnings: []string{"Warning: Value memcached-operator.v0.0.1: owned CRD \"memcacheds.cache.example.com\" has an empty description"},
+			},
+		},
+		{
+			name:    "no results",
+			out:     "Error: open bundle/manifests: no such file or directory\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed results",
+			out:     `{"passed": "yes"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBundleValidation([]byte(tt.out))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundleValidationErr(t *testing.T) {
+	tests := []struct {
+		name           string
+		bv             BundleValidation
+		failOnWarnings bool
+		wantErr        bool
+	}{
+		{name: "passed", bv: BundleValidation{Passed: true}},
+		{name: "warnings are allowed", bv: BundleValidation{Passed: true, Warnings: []string{"warning"}}},
+		{name: "warnings fail", bv: BundleValidation{Passed: true, Warnings: []string{"warning"}}, failOnWarnings: true, wantErr: true},
+		{name: "errors fail", bv: BundleValidation{Errors: []string{"error"}}, wantErr: true},
+		{name: "not passed without messages", bv: BundleValidation{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.bv.Err(tt.failOnWarnings); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package e2e
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Cleanup is a registry of undo actions. Helpers that change the cluster register
+// the action that reverts their change, even if the change only partially succeeded,
+// and Run executes the actions in the reverse order they were registered.
+type Cleanup struct {
+	mu      sync.Mutex
+	actions []cleanupAction
+}
+
+type cleanupAction struct {
+	name string
+	undo func() error
+}
+
+// NewCleanup creates an empty Cleanup. The e2eginkgo and e2etesting packages
+// provide constructors that run it automatically when the spec or test finishes.
+func NewCleanup() *Cleanup {
+	return &Cleanup{}
+}
+
+// Add registers an undo action. The name is used to identify the action in errors.
+func (c *Cleanup) Add(name string, undo func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.actions = append(c.actions, cleanupAction{name: name, undo: undo})
+}
+
+// Run executes the registered undo actions in reverse order. Every action is run
+// regardless of earlier failures and the errors are returned as an aggregate.
+// Actions are removed once run, so calling Run again only runs newly added actions.
+func (c *Cleanup) Run() error {
+	c.mu.Lock()
+	actions := c.actions
+	c.actions = nil
+	c.mu.Unlock()
+
+	var errs []error
+	for i := len(actions) - 1; i >= 0; i-- {
+		if err := actions[i].undo(); err != nil {
+			errs = append(errs, fmt.Errorf("encountered an error when cleaning up %s: %w", actions[i].name, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// InstallDependency installs the Dependency and registers its uninstall
+func (c *Cleanup) InstallDependency(dep Dependency, kubectl kubernetes.Kubectl) error {
+	c.Add(dep.Name(), func() error {
+		return UninstallDependency(dep, kubectl)
+	})
+
+	return InstallDependency(dep, kubectl)
+}
+
+// InstallDependencies installs every Dependency in order and registers the uninstall
+// of each one. It stops at the first Dependency that fails to install, in which case
+// only the attempted Dependencies are uninstalled.
+func (c *Cleanup) InstallDependencies(deps *Dependencies, kubectl kubernetes.Kubectl) error {
+	for _, dep := range deps.dependencies {
+		if err := c.InstallDependency(dep, kubectl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeployOperator deploys the operator and registers its undeploy
+func (c *Cleanup) DeployOperator(sample samples.Sample, image string) error {
+	c.Add("the operator deployment", func() error {
+		return UndeployOperator(sample)
+	})
+
+	return DeployOperator(sample, image)
+}
+
+// GrantMetricsAccess creates the metrics ClusterRoleBinding and registers its deletion
+func (c *Cleanup) GrantMetricsAccess(sample samples.Sample, kubectl kubernetes.Kubectl, clusterRoleBindingName string) error {
+	c.Add("the metrics clusterrolebinding", func() error {
+		return deleteIgnoreNotFound(kubectl, false, "clusterrolebinding", clusterRoleBindingName)
+	})
+
+	return GrantMetricsAccess(sample, kubectl, clusterRoleBindingName)
+}
+
+// CreateResources creates the custom resources and registers their deletion
+func (c *Cleanup) CreateResources(kubectl kubernetes.Kubectl, timeout time.Duration, resources ...*samples.CustomResource) error {
+	c.Add("the custom resources", func() error {
+		return DeleteResources(kubectl, timeout, resources...)
+	})
+
+	return CreateResources(kubectl, resources...)
+}
+
+// GetMetrics gets the metrics with GetMetrics and registers the deletion of the
+// curl pod and metrics ClusterRoleBinding it creates
+func (c *Cleanup) GetMetrics(sample samples.Sample, kubectl kubernetes.Kubectl, metricsClusterRoleBindingName string) (Metrics, error) {
+	c.Add("the metrics curl pod and clusterrolebinding", func() error {
+		return CleanUpMetrics(kubectl, metricsClusterRoleBindingName)
+	})
+
+	return GetMetrics(sample, kubectl, metricsClusterRoleBindingName)
+}
+
+func deleteIgnoreNotFound(kubectl kubernetes.Kubectl, inNamespace bool, options ...string) error {
+	out, err := kubectl.Delete(inNamespace, append(options, "--ignore-not-found")...)
+	if err != nil {
+		return fmt.Errorf("%w, output: %s", err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package e2e
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/command"
+)
+
+// ContainerTool is a tool that can be used to build and save container images
+type ContainerTool string
+
+const (
+	Docker  ContainerTool = "docker"
+	Podman  ContainerTool = "podman"
+	Buildah ContainerTool = "buildah"
+)
+
+// ImageOptions configures how images are built and loaded onto a cluster
+type ImageOptions struct {
+	containerTool ContainerTool
+	archive       string
+	registry      string
+}
+
+type ImageOption func(io *ImageOptions)
+
+// WithContainerTool sets the ContainerTool used to build, save and push images
+func WithContainerTool(tool ContainerTool) ImageOption {
+	return func(io *ImageOptions) {
+		io.containerTool = tool
+	}
+}
+
+// WithImageArchive sets a path that a built image is additionally saved to as an OCI archive
+func WithImageArchive(path string) ImageOption {
+	return func(io *ImageOptions) {
+		io.archive = path
+	}
+}
+
+// WithImageRegistry sets the host:port of a registry that images are pushed to
+// instead of being loaded onto the cluster
+func WithImageRegistry(registry string) ImageOption {
+	return func(io *ImageOptions) {
+		io.registry = registry
+	}
+}
+
+func newImageOptions(opts ...ImageOption) *ImageOptions {
+	io := &ImageOptions{
+		containerTool: Docker,
+	}
+
+	for _, opt := range opts {
+		opt(io)
+	}
+
+	return io
+}
+
+// ArchiveFormat is the format that an image archive is saved in
+type ArchiveFormat string
+
+const (
+	DockerArchive ArchiveFormat = "docker-archive"
+	OCIArchive    ArchiveFormat = "oci-archive"
+)
+
+// SaveImageArchive saves a locally built image to an archive at the given path.
+// Docker always saves in its own format which recent versions make OCI compliant.
+func SaveImageArchive(cc command.CommandContext, tool ContainerTool, image string, path string, format ArchiveFormat) error {
+	var cmd *exec.Cmd
+	switch tool {
+	case Podman:
+		cmd = exec.Command(string(tool), "save", "--format", string(format), "-o", path, image)
+	case Buildah:
+		cmd = exec.Command(string(tool), "push", image, fmt.Sprintf("%s:%s:%s", format, path, image))
+	default:
+		cmd = exec.Command(string(tool), "save", "-o", path, image)
+	}
+
+	out, err := cc.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when saving image %q to %q: %w, output: %s", image, path, err, string(out))
+	}
+
+	return nil
+}
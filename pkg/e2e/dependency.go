@@ -0,0 +1,312 @@
+package e2e
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbutil "sigs.k8s.io/kubebuilder/v3/pkg/plugin/util"
+)
+
+// Dependency is something that needs to be present on a cluster
+// for an operator to be tested, i.e cert-manager or the Prometheus Operator
+type Dependency interface {
+	// Name returns the name of the Dependency
+	Name() string
+	// Install installs the Dependency on the cluster
+	Install(kubectl kubernetes.Kubectl) error
+	// WaitReady blocks until the Dependency is ready to be used
+	WaitReady(kubectl kubernetes.Kubectl) error
+	// Uninstall removes the Dependency from the cluster
+	Uninstall(kubectl kubernetes.Kubectl) error
+	// WaitGone blocks until all resources of the Dependency have been removed
+	WaitGone(kubectl kubernetes.Kubectl) error
+}
+
+// Dependencies is an ordered set of Dependency. Dependencies are installed
+// in the order they are registered and uninstalled in the reverse order.
+type Dependencies struct {
+	dependencies []Dependency
+}
+
+// NewDependencies creates a new Dependencies with the provided Dependency registered
+func NewDependencies(deps ...Dependency) *Dependencies {
+	d := &Dependencies{}
+	d.Register(deps...)
+	return d
+}
+
+// Register adds the provided Dependency to the set
+func (d *Dependencies) Register(deps ...Dependency) {
+	d.dependencies = append(d.dependencies, deps...)
+}
+
+// InstallAll installs every registered Dependency and waits for it to be ready
+// before installing the next. It stops at the first Dependency that fails.
+func (d *Dependencies) InstallAll(kubectl kubernetes.Kubectl) error {
+	for _, dep := range d.dependencies {
+		if err := InstallDependency(dep, kubectl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UninstallAll uninstalls every registered Dependency in reverse order. An error
+// uninstalling one Dependency does not prevent the others from being uninstalled.
+func (d *Dependencies) UninstallAll(kubectl kubernetes.Kubectl) error {
+	var errs []error
+	for i := len(d.dependencies) - 1; i >= 0; i-- {
+		if err := UninstallDependency(d.dependencies[i], kubectl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// InstallDependency installs the Dependency and waits for it to be ready
+func InstallDependency(dep Dependency, kubectl kubernetes.Kubectl) error {
+	if err := dep.Install(kubectl); err != nil {
+		return fmt.Errorf("encountered an error when installing %s: %w", dep.Name(), err)
+	}
+
+	if err := dep.WaitReady(kubectl); err != nil {
+		return fmt.Errorf("encountered an error when waiting for %s to be ready: %w", dep.Name(), err)
+	}
+
+	return nil
+}
+
+// UninstallDependency uninstalls the Dependency and waits for it to be removed
+func UninstallDependency(dep Dependency, kubectl kubernetes.Kubectl) error {
+	if err := dep.Uninstall(kubectl); err != nil {
+		return fmt.Errorf("encountered an error when uninstalling %s: %w", dep.Name(), err)
+	}
+
+	if err := dep.WaitGone(kubectl); err != nil {
+		return fmt.Errorf("encountered an error when waiting for %s to be removed: %w", dep.Name(), err)
+	}
+
+	return nil
+}
+
+// ManifestDependency is a Dependency that is installed by applying a set of manifests.
+// It is considered ready once all CustomResourceDefinitions in the manifests are
+// Established and all Deployments in the manifests are Available.
+type ManifestDependency struct {
+	name         string
+	manifest     func(kubectl kubernetes.Kubectl) (string, error)
+	applyOptions []string
+	timeout      time.Duration
+}
+
+type ManifestDependencyOption func(md *ManifestDependency)
+
+// WithApplyOptions sets extra options passed to `kubectl apply` when installing the manifests
+func WithApplyOptions(options ...string) ManifestDependencyOption {
+	return func(md *ManifestDependency) {
+		md.applyOptions = make([]string, len(options))
+		copy(md.applyOptions, options)
+	}
+}
+
+// WithTimeout sets how long to wait for the Dependency to become ready or be removed
+func WithTimeout(timeout time.Duration) ManifestDependencyOption {
+	return func(md *ManifestDependency) {
+		md.timeout = timeout
+	}
+}
+
+// NewManifestDependency creates a new ManifestDependency that installs the
+// manifests for the given version resolved from the ManifestSource
+func NewManifestDependency(name string, source ManifestSource, version string, opts ...ManifestDependencyOption) *ManifestDependency {
+	return newManifestDependency(name, func(kubectl kubernetes.Kubectl) (string, error) {
+		return source.Resolve(version)
+	}, opts...)
+}
+
+func newManifestDependency(name string, manifest func(kubectl kubernetes.Kubectl) (string, error), opts ...ManifestDependencyOption) *ManifestDependency {
+	md := &ManifestDependency{
+		name:     name,
+		manifest: manifest,
+		timeout:  5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(md)
+	}
+
+	return md
+}
+
+// NewCertManager creates a ManifestDependency that installs cert-manager
+func NewCertManager(hasv1beta1CRs bool, opts ...ManifestOption) *ManifestDependency {
+	return newManifestDependency("cert-manager", func(kubectl kubernetes.Kubectl) (string, error) {
+		return getCertManagerManifest(hasv1beta1CRs, kubectl, opts...)
+	}, WithApplyOptions("--validate=false"))
+}
+
+// NewPrometheusOperator creates a ManifestDependency that installs the Prometheus Operator.
+// The manifests are applied server-side since the CRDs of recent releases are too large
+// for the last-applied-configuration annotation of a client-side apply.
+func NewPrometheusOperator(opts ...ManifestOption) *ManifestDependency {
+	return newManifestDependency("prometheus-operator", func(kubectl kubernetes.Kubectl) (string, error) {
+		return getPrometheusOperatorManifest(kubectl, opts...)
+	}, WithApplyOptions("--server-side", "--force-conflicts"))
+}
+
+func (md *ManifestDependency) Name() string {
+	return md.name
+}
+
+func (md *ManifestDependency) Install(kubectl kubernetes.Kubectl) error {
+	manifest, err := md.manifest(kubectl)
+	if err != nil {
+		return fmt.Errorf("encountered an error when resolving the manifests: %w", err)
+	}
+
+	opts := append([]string{"-f", manifest}, md.applyOptions...)
+	_, err = kubectl.Apply(false, opts...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when applying the manifests: %w", err)
+	}
+
+	return nil
+}
+
+func (md *ManifestDependency) WaitReady(kubectl kubernetes.Kubectl) error {
+	manifest, err := md.manifest(kubectl)
+	if err != nil {
+		return fmt.Errorf("encountered an error when resolving the manifests: %w", err)
+	}
+
+	objects, err := getManifestObjects(kubectl, manifest, false)
+	if err != nil {
+		return err
+	}
+
+	var crds []string
+	deployments := make(map[string][]string)
+	for _, obj := range objects {
+		switch obj.kind {
+		case "CustomResourceDefinition":
+			crds = append(crds, "customresourcedefinition/"+obj.name)
+		case "Deployment":
+			deployments[obj.namespace] = append(deployments[obj.namespace], "deployment.apps/"+obj.name)
+		}
+	}
+
+	if len(crds) > 0 {
+		opts := append(crds, "--for", "condition=Established", "--timeout", md.timeout.String())
+		_, err = kubectl.Wait(false, opts...)
+		if err != nil {
+			return fmt.Errorf("encountered an error when waiting for CRDs to be established: %w", err)
+		}
+	}
+
+	for namespace, names := range deployments {
+		opts := append(names, "--for", "condition=Available", "--namespace", namespace, "--timeout", md.timeout.String())
+		_, err = kubectl.Wait(false, opts...)
+		if err != nil {
+			return fmt.Errorf("encountered an error when waiting for deployments in namespace %q to be available: %w", namespace, err)
+		}
+	}
+
+	return nil
+}
+
+func (md *ManifestDependency) Uninstall(kubectl kubernetes.Kubectl) error {
+	manifest, err := md.manifest(kubectl)
+	if err != nil {
+		return fmt.Errorf("encountered an error when resolving the manifests: %w", err)
+	}
+
+	_, err = kubectl.Delete(false, "-f", manifest, "--ignore-not-found")
+	if err != nil {
+		return fmt.Errorf("encountered an error when deleting the manifests: %w", err)
+	}
+
+	return nil
+}
+
+func (md *ManifestDependency) WaitGone(kubectl kubernetes.Kubectl) error {
+	manifest, err := md.manifest(kubectl)
+	if err != nil {
+		return fmt.Errorf("encountered an error when resolving the manifests: %w", err)
+	}
+
+	var remaining []manifestObject
+	err = wait.PollImmediate(time.Second, md.timeout, func() (bool, error) {
+		// The CRDs of the dependency can be removed before its custom resources,
+		// in which case those custom resources are gone as well
+		remaining, err = getManifestObjects(kubectl, manifest, true)
+		if err != nil {
+			return false, err
+		}
+		return len(remaining) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("encountered an error when waiting for %d remaining object(s) to be deleted: %w", len(remaining), err)
+	}
+
+	return nil
+}
+
+type manifestObject struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// missingKindMessages are the kubectl errors for objects whose kind is not served by the cluster
+var missingKindMessages = []string{
+	"no matches for kind",
+	"the server doesn't have a resource type",
+}
+
+// getManifestObjects returns the objects from the manifests that currently exist on the cluster.
+// If ignoreMissingKinds is true objects whose kind is not served by the cluster are treated as not existing.
+func getManifestObjects(kubectl kubernetes.Kubectl, manifest string, ignoreMissingKinds bool) ([]manifestObject, error) {
+	out, err := kubectl.Get(false, "-f", manifest, "--ignore-not-found", "--no-headers",
+		"-o", "custom-columns=KIND:.kind,NAMESPACE:.metadata.namespace,NAME:.metadata.name")
+	if err != nil && !(ignoreMissingKinds && isMissingKindError(out)) {
+		return nil, fmt.Errorf("encountered an error when getting the manifest objects: %w", err)
+	}
+
+	var objects []manifestObject
+	for _, line := range kbutil.GetNonEmptyLines(out) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+
+		namespace := fields[1]
+		if namespace == "<none>" {
+			namespace = ""
+		}
+
+		objects = append(objects, manifestObject{
+			kind:      fields[0],
+			namespace: namespace,
+			name:      fields[2],
+		})
+	}
+
+	return objects, nil
+}
+
+// isMissingKindError returns true if the kubectl output reports a kind that is not served by the cluster
+func isMissingKindError(out string) bool {
+	for _, message := range missingKindMessages {
+		if strings.Contains(out, message) {
+			return true
+		}
+	}
+
+	return false
+}
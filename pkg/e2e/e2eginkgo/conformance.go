@@ -0,0 +1,301 @@
+package e2eginkgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// ConformanceOptions configures the specs registered by ConformanceSuite
+type ConformanceOptions struct {
+	image         string
+	kubectl       kubernetes.Kubectl
+	local         bool
+	webhooks      bool
+	metrics       bool
+	certManager   bool
+	prometheus    bool
+	imageOpts     []e2e.ImageOption
+	reconcileOpts []e2e.ReconcileOption
+	dependencies  []e2e.Dependency
+	timeout       time.Duration
+	specs         []func(cc *ConformanceContext)
+}
+
+type ConformanceOption func(co *ConformanceOptions)
+
+// WithImage sets the operator image that is built and deployed.
+// Defaults to "<sample name>:conformance".
+func WithImage(image string) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.image = image
+	}
+}
+
+// WithKubectl sets the Kubectl used to interact with the cluster. Defaults to
+// the "<sample name>-system" namespace and "<sample name>-controller-manager" ServiceAccount.
+func WithKubectl(kubectl kubernetes.Kubectl) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.kubectl = kubectl
+	}
+}
+
+// WithLocalRun toggles the specs that run the operator locally. Enabled by default.
+func WithLocalRun(enabled bool) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.local = enabled
+	}
+}
+
+// WithWebhooks toggles the webhook specs. Enabling webhooks also installs cert-manager.
+func WithWebhooks(enabled bool) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.webhooks = enabled
+	}
+}
+
+// WithMetrics toggles the metrics specs. Enabled by default.
+func WithMetrics(enabled bool) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.metrics = enabled
+	}
+}
+
+// WithCertManager toggles installing cert-manager before deploying the operator
+func WithCertManager(enabled bool) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.certManager = enabled
+	}
+}
+
+// WithPrometheus toggles installing the Prometheus Operator before deploying
+// the operator and the spec that checks the ServiceMonitor is created
+func WithPrometheus(enabled bool) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.prometheus = enabled
+	}
+}
+
+// WithImageOptions sets the options used when building and loading the operator image
+func WithImageOptions(opts ...e2e.ImageOption) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.imageOpts = append(co.imageOpts, opts...)
+	}
+}
+
+// WithReconcileOptions sets what is expected of the sample custom resource once reconciled
+func WithReconcileOptions(opts ...e2e.ReconcileOption) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.reconcileOpts = append(co.reconcileOpts, opts...)
+	}
+}
+
+// WithDependencies sets extra dependencies that are installed after cert-manager and the
+// Prometheus Operator, i.e OLM, and uninstalled once the cluster specs have finished
+func WithDependencies(deps ...e2e.Dependency) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.dependencies = append(co.dependencies, deps...)
+	}
+}
+
+// WithConformanceTimeout sets how long to wait for the operator and custom resource
+func WithConformanceTimeout(timeout time.Duration) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.timeout = timeout
+	}
+}
+
+// WithSpecs adds plugin specific specs. The function is called while the spec tree is
+// built, inside the ordered cluster container, so it should only register nodes, i.e It.
+// The specs run after the standard specs, against the deployed operator.
+func WithSpecs(specs func(cc *ConformanceContext)) ConformanceOption {
+	return func(co *ConformanceOptions) {
+		co.specs = append(co.specs, specs)
+	}
+}
+
+// ConformanceContext gives specs added with WithSpecs access to the state of the suite.
+// Its values are only set once the cluster setup has run, so they must be read from
+// within a spec and not while the tree is built.
+type ConformanceContext struct {
+	sample  samples.Sample
+	kubectl kubernetes.Kubectl
+	image   string
+	cleanup *e2e.Cleanup
+}
+
+// Sample returns the sample under test
+func (cc *ConformanceContext) Sample() samples.Sample {
+	return cc.sample
+}
+
+// Kubectl returns the Kubectl used by the suite
+func (cc *ConformanceContext) Kubectl() kubernetes.Kubectl {
+	return cc.kubectl
+}
+
+// Image returns the operator image that was deployed, as loaded into the cluster
+func (cc *ConformanceContext) Image() string {
+	return cc.image
+}
+
+// Cleanup returns the Cleanup of the cluster specs. Anything registered
+// with it is undone before the operator is undeployed.
+func (cc *ConformanceContext) Cleanup() *e2e.Cleanup {
+	return cc.cleanup
+}
+
+// ConformanceSuite registers the standard ordered spec tree every plugin is expected to
+// pass: running locally, then building, loading and deploying the operator, checking
+// the controller, metrics service, ServiceMonitor and webhooks, creating and reconciling
+// the sample custom resource and scraping the metrics. It returns
+// the result of Describe so it can be used as `var _ = e2eginkgo.ConformanceSuite(...)`.
+func ConformanceSuite(sample samples.Sample, opts ...ConformanceOption) bool {
+	co := &ConformanceOptions{
+		image:   sample.Name() + ":conformance",
+		local:   true,
+		metrics: true,
+		timeout: 2 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(co)
+	}
+
+	if co.kubectl == nil {
+		co.kubectl = kubernetes.NewKubectlUtil(
+			kubernetes.WithNamespace(sample.Name()+"-system"),
+			kubernetes.WithServiceAccount(sample.Name()+"-controller-manager"),
+		)
+	}
+
+	cc := &ConformanceContext{
+		sample:  sample,
+		kubectl: co.kubectl,
+	}
+
+	return Describe(sample.Name()+" conformance", Ordered, func() {
+		if co.local {
+			Context("Running locally", func() {
+				LocalTest(sample)
+			})
+		}
+
+		Context("Running on cluster", Ordered, func() {
+			BeforeAll(func() {
+				cc.cleanup = Cleanup()
+				setUpCluster(cc, co)
+			})
+
+			registerClusterSpecs(cc, co)
+
+			for _, specs := range co.specs {
+				specs(cc)
+			}
+		})
+	})
+}
+
+// setUpCluster installs the dependencies, then builds, loads and deploys the operator
+func setUpCluster(cc *ConformanceContext, co *ConformanceOptions) {
+	kctl := cc.kubectl
+
+	deps := e2e.NewDependencies()
+	if co.certManager || co.webhooks {
+		deps.Register(e2e.NewCertManager(false))
+	}
+	if co.prometheus {
+		deps.Register(e2e.NewPrometheusOperator())
+	}
+	deps.Register(co.dependencies...)
+
+	By("Installing dependencies")
+	Expect(cc.cleanup.InstallDependencies(deps, kctl)).To(Succeed())
+
+	By("Building the operator image")
+	Expect(e2e.BuildOperatorImage(cc.sample, co.image, co.imageOpts...)).To(Succeed())
+
+	cc.image = co.image
+	By("Loading the image to the cluster")
+	loader, err := e2e.DetectClusterImageLoader(kctl, co.imageOpts...)
+	if errors.Is(err, e2e.ErrNoClusterImageLoader) {
+		Fail(fmt.Sprintf("%v, the cluster would not be able to pull image %q: "+
+			"use a kind, k3d or minikube cluster, or set a registry to push the image to with e2e.WithImageRegistry or %s",
+			err, co.image, e2e.ImageRegistryEnv))
+	}
+	Expect(err).NotTo(HaveOccurred())
+
+	cc.image, err = loader.LoadImage(co.image)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("Deploying the operator")
+	Expect(cc.cleanup.DeployOperator(cc.sample, cc.image)).To(Succeed())
+}
+
+// registerClusterSpecs registers the standard specs in the order from notes.md
+func registerClusterSpecs(cc *ConformanceContext, co *ConformanceOptions) {
+	It("Should have the controller running", func() {
+		Eventually(func() error {
+			return e2e.EnsureOperatorRunning(cc.kubectl, 1, "controller-manager", "controller-manager")
+		}, co.timeout, time.Second).Should(Succeed())
+	})
+
+	if co.prometheus {
+		It("Should create a ServiceMonitor for the manager", func() {
+			out, err := cc.kubectl.Get(true, "ServiceMonitor",
+				fmt.Sprintf("%s-controller-manager-metrics-monitor", cc.sample.Name()))
+			Expect(err).NotTo(HaveOccurred(), out)
+		})
+	}
+
+	if co.metrics {
+		It("Should create the metrics Service for the manager", func() {
+			out, err := cc.kubectl.Get(true, "Service",
+				fmt.Sprintf("%s-controller-manager-metrics-service", cc.sample.Name()))
+			Expect(err).NotTo(HaveOccurred(), out)
+		})
+	}
+
+	if co.webhooks {
+		It("Should serve the webhooks", func() {
+			Expect(e2e.WaitForWebhookReady(cc.sample, cc.kubectl, co.timeout)).To(Succeed())
+		})
+	}
+
+	It("Should reconcile the sample CustomResource", func() {
+		cr, err := samples.LoadCustomResource(cc.sample)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Creating the CustomResource")
+		cc.cleanup.Add("the sample custom resource", func() error {
+			return e2e.DeleteResources(cc.kubectl, co.timeout, cr)
+		})
+		// Creating can fail until the CRD is served and the webhooks are ready
+		Eventually(func() error {
+			return e2e.CreateResources(cc.kubectl, cr)
+		}, time.Minute, time.Second).Should(Succeed())
+
+		By("Ensuring the CustomResource is reconciled")
+		reconcileOpts := append([]e2e.ReconcileOption{e2e.WithReconcileTimeout(co.timeout)}, co.reconcileOpts...)
+		Expect(e2e.ExpectReconciled(cc.sample, cc.kubectl, reconcileOpts...)).To(Succeed())
+	})
+
+	if co.metrics {
+		It("Should serve the metrics", func() {
+			By("Getting the metrics")
+			metrics, err := cc.cleanup.GetMetrics(cc.sample, cc.kubectl, fmt.Sprintf("%s-metrics-reader", cc.sample.Name()))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(metrics).To(e2e.HaveMetric("controller_runtime_reconcile_total",
+				e2e.MetricLabels{"controller": strings.ToLower(cc.sample.GVK().Kind)},
+				BeNumerically(">", 0)))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Package e2eginkgo adapts the helpers of the e2e package for use in Ginkgo suites.
+// Each step is reported with By and any error fails the current spec.
+package e2eginkgo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// LocalTest adds specs that install the CRDs of the sample and run the operator locally
+func LocalTest(sample samples.Sample) {
+	BeforeEach(func() {
+		By("Installing CRD's")
+		Expect(e2e.InstallCRDs(sample)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		By("Uninstalling CRD's")
+		Expect(e2e.UninstallCRDs(sample)).To(Succeed())
+	})
+
+	It("Should run correctly when run locally", func() {
+		By("Running the project")
+		Expect(e2e.RunLocally(sample, 5*time.Second)).To(Succeed())
+	})
+}
+
+// Cleanup creates an e2e.Cleanup that is run with DeferCleanup. It must be called
+// from a setup node or spec, i.e BeforeAll, and any error fails that node.
+func Cleanup() *e2e.Cleanup {
+	c := e2e.NewCleanup()
+	DeferCleanup(c.Run)
+	return c
+}
+
+// GetMetrics gets the operator metrics with e2e.GetMetrics
+func GetMetrics(sample samples.Sample, kubectl kubernetes.Kubectl, metricsClusterRoleBindingName string) e2e.Metrics {
+	By("getting the metrics from a curl pod")
+	metrics, err := e2e.GetMetrics(sample, kubectl, metricsClusterRoleBindingName)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return metrics
+}
+
+// CleanUpTestDir removes the directory the samples were generated in
+func CleanUpTestDir(path string) {
+	ExpectWithOffset(1, e2e.CleanUpTestDir(path)).To(Succeed())
+}
+
+// TestNamespace creates a unique namespace with kubernetes.CreateTestNamespace that is
+// deleted with DeferCleanup, so it must be called from a setup node or spec
+func TestNamespace(kubectl kubernetes.Kubectl, opts ...kubernetes.TestNamespaceOption) *kubernetes.TestNamespace {
+	By("creating a test namespace")
+	ns, err := kubernetes.CreateTestNamespace(kubectl, opts...)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	DeferCleanup(ns.Delete)
+	return ns
+}
+
+// Verify adds a spec for each check, or samples.DefaultChecks if none are given, that
+// runs it against the sample with samples.Verify. The output and duration of each check
+// are added to the report.
+func Verify(sample samples.Sample, checks ...samples.Check) {
+	if len(checks) == 0 {
+		checks = samples.DefaultChecks()
+	}
+
+	for _, check := range checks {
+		check := check
+		It(fmt.Sprintf("Should pass %s", check.Name), func() {
+			By(fmt.Sprintf("Running %s", check.Name))
+			report := samples.Verify(sample, check)
+			AddReportEntry(check.Name, report[0].Duration, report[0].Output)
+			Expect(report.Err()).NotTo(HaveOccurred())
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package e2etesting adapts the helpers of the e2e package for use with the standard
+// testing package. Any error is reported with t.Fatal, stopping the test.
+package e2etesting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// LocalTest installs the CRDs of the sample, runs the operator locally and
+// uninstalls the CRDs again when the test finishes
+func LocalTest(t *testing.T, sample samples.Sample) {
+	t.Helper()
+
+	if err := e2e.InstallCRDs(sample); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := e2e.UninstallCRDs(sample); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if err := e2e.RunLocally(sample, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Cleanup creates an e2e.Cleanup that is run with t.Cleanup and reports any error with t.Error
+func Cleanup(t testing.TB) *e2e.Cleanup {
+	c := e2e.NewCleanup()
+	t.Cleanup(func() {
+		if err := c.Run(); err != nil {
+			t.Error(err)
+		}
+	})
+	return c
+}
+
+// GetMetrics gets the operator metrics with e2e.GetMetrics
+func GetMetrics(t testing.TB, sample samples.Sample, kubectl kubernetes.Kubectl, metricsClusterRoleBindingName string) e2e.Metrics {
+	t.Helper()
+
+	metrics, err := e2e.GetMetrics(sample, kubectl, metricsClusterRoleBindingName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return metrics
+}
+
+// CleanUpTestDir removes the directory the samples were generated in
+func CleanUpTestDir(t testing.TB, path string) {
+	t.Helper()
+
+	if err := e2e.CleanUpTestDir(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestNamespace creates a unique namespace with kubernetes.CreateTestNamespace
+// that is deleted when the test finishes
+func TestNamespace(t testing.TB, kubectl kubernetes.Kubectl, opts ...kubernetes.TestNamespaceOption) *kubernetes.TestNamespace {
+	t.Helper()
+
+	ns, err := kubernetes.CreateTestNamespace(kubectl, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := ns.Delete(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return ns
+}
+
+// Eventually polls the condition every interval until it returns nil and fails the
+// test with the last error if that does not happen within the timeout. It is the
+// equivalent of Gomega's Eventually(...).Should(Succeed()), i.e for e2e.EnsureOperatorRunning.
+func Eventually(t testing.TB, timeout time.Duration, interval time.Duration, condition func() error) {
+	t.Helper()
+
+	var lastErr error
+	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
+		lastErr = condition()
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("condition not met within %s: %v", timeout, lastErr)
+	}
+}
+
+// Verify runs each check, or samples.DefaultChecks if none are given, against the sample
+// as a subtest with samples.Verify, logging its output and duration
+func Verify(t *testing.T, sample samples.Sample, checks ...samples.Check) {
+	t.Helper()
+
+	if len(checks) == 0 {
+		checks = samples.DefaultChecks()
+	}
+
+	for _, check := range checks {
+		check := check
+		t.Run(check.Name, func(t *testing.T) {
+			report := samples.Verify(sample, check)
+			t.Logf("%s took %s, output:\n%s", check.Name, report[0].Duration, report[0].Output)
+			if err := report.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
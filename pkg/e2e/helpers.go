@@ -1,58 +1,94 @@
 package e2e
 
 import (
-	"encoding/base64"
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/everettraven/plugin-testing-poc/pkg/command"
 	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
 	"github.com/everettraven/plugin-testing-poc/pkg/samples"
-	. "github.com/onsi/ginkgo/v2"
-	. "github.com/onsi/gomega"
-
+	"k8s.io/apimachinery/pkg/util/wait"
 	kbutil "sigs.k8s.io/kubebuilder/v3/pkg/plugin/util"
 )
 
-func LocalTest(sample samples.Sample) {
-	BeforeEach(func() {
-		By("Installing CRD's")
-		cmd := exec.Command("make", "install")
-		cmdCtx := sample.CommandContext()
-		_, err := cmdCtx.Run(cmd, sample.Name())
-		Expect(err).NotTo(HaveOccurred())
-	})
+// InstallCRDs installs the CRDs of the sample with `make install`
+func InstallCRDs(sample samples.Sample) error {
+	cmd := exec.Command("make", "install")
+	out, err := sample.CommandContext().Run(cmd, sample.Name())
+	if err != nil {
+		return fmt.Errorf("encountered an error when installing the CRDs: %w, output: %s", err, out)
+	}
 
-	AfterEach(func() {
-		By("Uninstalling CRD's")
-		cmd := exec.Command("make", "uninstall")
-		cmdCtx := sample.CommandContext()
-		_, err := cmdCtx.Run(cmd, sample.Name())
-		Expect(err).NotTo(HaveOccurred())
-	})
+	return nil
+}
 
-	It("Should run correctly when run locally", func() {
-		By("Running the project")
-		cmd := exec.Command("make", "run")
-		err := cmd.Start()
-		Expect(err).NotTo(HaveOccurred())
+// UninstallCRDs uninstalls the CRDs of the sample with `make uninstall`
+func UninstallCRDs(sample samples.Sample) error {
+	cmd := exec.Command("make", "uninstall")
+	out, err := sample.CommandContext().Run(cmd, sample.Name())
+	if err != nil {
+		return fmt.Errorf("encountered an error when uninstalling the CRDs: %w, output: %s", err, out)
+	}
 
-		By("Killing the project")
-		err = cmd.Process.Kill()
-		Expect(err).NotTo(HaveOccurred())
-	})
+	return nil
 }
 
-func BuildOperatorImage(sample samples.Sample, image string) error {
-	cmd := exec.Command("make", "docker-build", "IMG="+image)
+// RunLocally runs the operator outside of the cluster with `make run` and stops it
+// after the given duration. An error is returned if the operator exits before then.
+func RunLocally(sample samples.Sample, duration time.Duration) error {
+	cc := sample.CommandContext()
+
+	var out bytes.Buffer
+	cmd := exec.Command("make", "run")
+	cmd.Dir = strings.Join([]string{cc.Dir(), sample.Name()}, "/")
+	cmd.Env = append(os.Environ(), cc.Env()...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("encountered an error when running the operator locally: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		return fmt.Errorf("operator exited while running locally: %v, output: %s", err, out.String())
+	case <-time.After(duration):
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("encountered an error when stopping the operator: %w", err)
+	}
+	<-exited
+
+	return nil
+}
+
+// BuildOperatorImage builds the operator image with `make docker-build`. The ContainerTool
+// set with WithContainerTool is passed to make as CONTAINER_TOOL and, if WithImageArchive
+// is set, the built image is saved to the archive so it can be loaded by archive based loaders.
+func BuildOperatorImage(sample samples.Sample, image string, opts ...ImageOption) error {
+	io := newImageOptions(opts...)
+
+	cmd := exec.Command("make", "docker-build", "IMG="+image, "CONTAINER_TOOL="+string(io.containerTool))
 	_, err := sample.CommandContext().Run(cmd, sample.Name())
 	if err != nil {
-		fmt.Errorf("encountered an error when building the operator image: %w", err)
+		return fmt.Errorf("encountered an error when building the operator image: %w", err)
+	}
+
+	if io.archive != "" {
+		err = SaveImageArchive(sample.CommandContext(), io.containerTool, image, io.archive, OCIArchive)
+		if err != nil {
+			return fmt.Errorf("encountered an error when saving the operator image: %w", err)
+		}
 	}
 
 	return nil
@@ -62,7 +98,7 @@ func DeployOperator(sample samples.Sample, image string) error {
 	cmd := exec.Command("make", "deploy", "IMG="+image)
 	_, err := sample.CommandContext().Run(cmd, sample.Name())
 	if err != nil {
-		fmt.Errorf("encountered an error when deploying the operator: %w", err)
+		return fmt.Errorf("encountered an error when deploying the operator: %w", err)
 	}
 
 	return nil
@@ -72,216 +108,217 @@ func UndeployOperator(sample samples.Sample) error {
 	cmd := exec.Command("make", "undeploy")
 	_, err := sample.CommandContext().Run(cmd, sample.Name())
 	if err != nil {
-		fmt.Errorf("encountered an error when undeploying the operator: %w", err)
+		return fmt.Errorf("encountered an error when undeploying the operator: %w", err)
 	}
 
 	return nil
 }
 
-func InstallPrometheusOperator(kubectl kubernetes.Kubectl) error {
-	url, err := getPrometheusOperatorUrl(kubectl)
-	if err != nil {
-		return fmt.Errorf("encountered an error when getting the bundle URL: %w", err)
-	}
+// The default manifests are fetched from the upstream releases, no manifests are embedded
+// in this package. To install without network access the test suite has to embed the
+// manifests itself, and to pin a different release pass WithVersion, i.e
+//
+//	//go:embed manifests
+//	var manifests embed.FS
+//	e2e.NewCertManager(false,
+//		e2e.WithManifestSource(e2e.NewEmbeddedManifestSource(manifests, "manifests/cert-manager-%s.yaml")),
+//		e2e.WithVersion("v1.14.5"))
+const (
+	// DefaultPrometheusOperatorVersion is the version of the Prometheus Operator
+	// that is installed when no version is specified
+	DefaultPrometheusOperatorVersion = "0.72"
+	// DefaultCertManagerVersion is the version of cert-manager that is installed
+	// when no version is specified, on Kubernetes 1.24+
+	DefaultCertManagerVersion = "v1.14.5"
+
+	prometheusOperatorLegacyVersion = "0.33"
+	prometheusOperatorLegacyURL     = "https://raw.githubusercontent.com/coreos/prometheus-operator/release-%s/bundle.yaml"
+	prometheusOperatorURL           = "https://raw.githubusercontent.com/prometheus-operator/" +
+		"prometheus-operator/release-%s/bundle.yaml"
 
-	_, err = kubectl.Apply(false, "-f", url)
-	if err != nil {
-		return fmt.Errorf("encountered an error when getting the bundle URL: %w", err)
-	}
+	certmanagerVersionWithv1beta2CRs = "v0.11.0"
+	certmanagerLegacyVersion         = "v1.0.4"
+	// certmanagerPre124Version is the last default for clusters older than
+	// Kubernetes 1.24, which newer cert-manager releases do not support
+	certmanagerPre124Version = "v1.5.3"
+	certmanagerURLTmplLegacy = "https://github.com/cert-manager/cert-manager/releases/download/%s/cert-manager-legacy.yaml"
+	certmanagerURLTmpl       = "https://github.com/cert-manager/cert-manager/releases/download/%s/cert-manager.yaml"
+)
 
-	return nil
+// InstallPrometheusOperator installs the Prometheus Operator and waits for it to be ready.
+// By default the manifests are applied from the upstream release URL, use WithManifestSource
+// and WithVersion to install from another source or a different version.
+func InstallPrometheusOperator(kubectl kubernetes.Kubectl, opts ...ManifestOption) error {
+	return InstallDependency(NewPrometheusOperator(opts...), kubectl)
 }
 
-func UninstallPrometheusOperator(kubectl kubernetes.Kubectl) error {
-	url, err := getPrometheusOperatorUrl(kubectl)
-	if err != nil {
-		return fmt.Errorf("encountered an error when getting the bundle URL: %w", err)
-	}
-	_, err = kubectl.Apply(false, "-f", url)
-	if err != nil {
-		return fmt.Errorf("encountered an error when deleting the bundle: %w", err)
-	}
-
-	return nil
+// UninstallPrometheusOperator uninstalls the Prometheus Operator and waits for it to be removed
+func UninstallPrometheusOperator(kubectl kubernetes.Kubectl, opts ...ManifestOption) error {
+	return UninstallDependency(NewPrometheusOperator(opts...), kubectl)
 }
 
-func getPrometheusOperatorUrl(kubectl kubernetes.Kubectl) (string, error) {
-	prometheusOperatorLegacyVersion := "0.33"
-	prometheusOperatorLegacyURL := "https://raw.githubusercontent.com/coreos/prometheus-operator/release-%s/bundle.yaml"
-	prometheusOperatorVersion := "0.51"
-	prometheusOperatorURL := "https://raw.githubusercontent.com/prometheus-operator/" +
-		"prometheus-operator/release-%s/bundle.yaml"
+func getPrometheusOperatorManifest(kubectl kubernetes.Kubectl, opts ...ManifestOption) (string, error) {
+	mo := newManifestOptions(opts...)
 
-	var url string
+	if mo.version == "" || mo.source == nil {
+		// Prometheus Operator versions after 0.33 require k8s v1.16+
+		supported, err := kubernetes.ServerVersionAtLeast(kubectl, 1, 16)
+		if err != nil {
+			return "", err
+		}
 
-	kubeVersion, err := kubectl.Version()
-	if err != nil {
-		return "", fmt.Errorf("encountered an error trying to get Kubernetes Version: %w", err)
-	}
-	serverMajor, err := strconv.ParseUint(kubeVersion.ServerVersion().Major(), 10, 64)
-	if err != nil {
-		return "", fmt.Errorf("encountered an error trying to parse Kubernetes Major Version: %w", err)
-	}
+		if mo.version == "" {
+			mo.version = DefaultPrometheusOperatorVersion
+			if !supported {
+				mo.version = prometheusOperatorLegacyVersion
+			}
+		}
 
-	serverMinor, err := strconv.ParseUint(kubeVersion.ServerVersion().Minor(), 10, 64)
-	if err != nil {
-		return "", fmt.Errorf("encountered an error trying to parse Kubernetes Minor Version: %w", err)
+		if mo.source == nil {
+			mo.source = NewURLManifestSource(prometheusOperatorURL)
+			if !supported {
+				mo.source = NewURLManifestSource(prometheusOperatorLegacyURL)
+			}
+		}
 	}
 
-	if serverMajor <= 1 && serverMinor < 16 {
-		url = fmt.Sprintf(prometheusOperatorLegacyURL, prometheusOperatorLegacyVersion)
-	} else {
-		url = fmt.Sprintf(prometheusOperatorURL, prometheusOperatorVersion)
-	}
+	return mo.source.Resolve(mo.version)
+}
 
-	return url, nil
+// InstallCertManagerBundle installs cert-manager and waits for it to be ready.
+// By default the manifests are applied from the upstream release URL, use WithManifestSource
+// and WithVersion to install from another source or a different version.
+func InstallCertManagerBundle(hasv1beta1CRs bool, kubectl kubernetes.Kubectl, opts ...ManifestOption) error {
+	return InstallDependency(NewCertManager(hasv1beta1CRs, opts...), kubectl)
 }
 
-func InstallCertManagerBundle(hasv1beta1CRs bool, kubectl kubernetes.Kubectl) error {
-	url, err := getCertManagerURL(hasv1beta1CRs, kubectl)
-	if err != nil {
-		return fmt.Errorf("encountered an error when getting the bundle URL: %w", err)
-	}
+// UninstallCertManagerBundle uninstalls cert-manager and waits for it to be removed
+func UninstallCertManagerBundle(hasv1beta1CRs bool, kubectl kubernetes.Kubectl, opts ...ManifestOption) error {
+	return UninstallDependency(NewCertManager(hasv1beta1CRs, opts...), kubectl)
+}
 
-	_, err = kubectl.Apply(false, "-f", url, "--validate=false")
-	if err != nil {
-		return fmt.Errorf("encountered an error when applying the bundle: %w", err)
-	}
-	// Wait for cert-manager-webhook to be ready, which can take time if cert-manager
-	// was re-installed after uninstalling on a cluster.
-	_, err = kubectl.Wait(false, "deployment.apps/cert-manager-webhook",
-		"--for", "condition=Available",
-		"--namespace", "cert-manager",
-		"--timeout", "5m",
-	)
-	if err != nil {
-		return fmt.Errorf("encountered an error when waiting for the webhook to be ready: %w", err)
-	}
+func getCertManagerManifest(hasv1beta1CRs bool, kubectl kubernetes.Kubectl, opts ...ManifestOption) (string, error) {
+	mo := newManifestOptions(opts...)
 
-	return nil
-}
+	if mo.version == "" || mo.source == nil {
+		// The most up-to-date bundle uses v1 CRDs, which were introduced in k8s v1.16.
+		supported, err := kubernetes.ServerVersionAtLeast(kubectl, 1, 16)
+		if err != nil {
+			return "", err
+		}
 
-func UninstallCertManagerBundle(hasv1beta1CRs bool, kubectl kubernetes.Kubectl) error {
-	url, err := getCertManagerURL(hasv1beta1CRs, kubectl)
-	if err != nil {
-		return fmt.Errorf("encountered an error when getting the bundle URL: %w", err)
-	}
+		// Use a manifest bundle with v1beta1 CRs if requested, otherwise
+		// determine which bundle to use for a manifest bundle with v1 CRs.
+		legacy := !supported && !hasv1beta1CRs
+
+		if mo.version == "" {
+			current, err := kubernetes.ServerVersionAtLeast(kubectl, 1, 24)
+			if err != nil {
+				return "", err
+			}
+
+			switch {
+			case hasv1beta1CRs:
+				mo.version = certmanagerVersionWithv1beta2CRs
+			case legacy:
+				mo.version = certmanagerLegacyVersion
+			case !current:
+				mo.version = certmanagerPre124Version
+			default:
+				mo.version = DefaultCertManagerVersion
+			}
+		}
 
-	_, err = kubectl.Delete(false, "-f", url)
-	if err != nil {
-		return fmt.Errorf("encountered an error when deleting the bundle: %w", err)
+		if mo.source == nil {
+			mo.source = NewURLManifestSource(certmanagerURLTmpl)
+			if legacy {
+				mo.source = NewURLManifestSource(certmanagerURLTmplLegacy)
+			}
+		}
 	}
 
-	return nil
+	return mo.source.Resolve(mo.version)
 }
 
-func getCertManagerURL(hasv1beta1CRs bool, kubectl kubernetes.Kubectl) (string, error) {
-	certmanagerVersionWithv1beta2CRs := "v0.11.0"
-	certmanagerLegacyVersion := "v1.0.4"
-	certmanagerVersion := "v1.5.3"
+// CleanUpTestDir removes the directory the samples were generated in
+func CleanUpTestDir(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("encountered an error when removing %s: %w", path, err)
+	}
 
-	certmanagerURLTmplLegacy := "https://github.com/jetstack/cert-manager/releases/download/%s/cert-manager-legacy.yaml"
-	certmanagerURLTmpl := "https://github.com/jetstack/cert-manager/releases/download/%s/cert-manager.yaml"
-	// Return a URL for the manifest bundle with v1beta1 CRs.
+	return nil
+}
 
-	kubeVersion, err := kubectl.Version()
-	if err != nil {
-		return "", fmt.Errorf("encountered an error trying to get Kubernetes Version: %w", err)
-	}
-	serverMajor, err := strconv.ParseUint(kubeVersion.ServerVersion().Major(), 10, 64)
-	if err != nil {
-		return "", fmt.Errorf("encountered an error trying to parse Kubernetes Major Version: %w", err)
+// GetMetrics scrapes the metrics endpoint of the operator from a curl pod and
+// returns the parsed metrics, which can be checked with the HaveMetric matcher.
+// The curl pod and ClusterRoleBinding can be removed with CleanUpMetrics.
+func GetMetrics(sample samples.Sample, kubectl kubernetes.Kubectl, metricsClusterRoleBindingName string) (Metrics, error) {
+	if err := GrantMetricsAccess(sample, kubectl, metricsClusterRoleBindingName); err != nil {
+		return nil, err
 	}
 
-	serverMinor, err := strconv.ParseUint(kubeVersion.ServerVersion().Minor(), 10, 64)
-	if err != nil {
-		return "", fmt.Errorf("encountered an error trying to parse Kubernetes Minor Version: %w", err)
+	token, cleanupToken, err := kubernetes.ServiceAccountToken(kubectl, kubectl.ServiceAccount(), "", 0)
+	if cleanupToken != nil {
+		defer cleanupToken()
 	}
-
-	if hasv1beta1CRs {
-		return fmt.Sprintf(certmanagerURLTmpl, certmanagerVersionWithv1beta2CRs), nil
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading the metrics token: %w", err)
 	}
-
-	// Determine which URL to use for a manifest bundle with v1 CRs.
-	// The most up-to-date bundle uses v1 CRDs, which were introduced in k8s v1.16.
-	if serverMajor <= 1 && serverMinor < 16 {
-		return fmt.Sprintf(certmanagerURLTmplLegacy, certmanagerLegacyVersion), nil
+	if token == "" {
+		return nil, fmt.Errorf("the metrics token for service account %q is empty", kubectl.ServiceAccount())
 	}
-	return fmt.Sprintf(certmanagerURLTmpl, certmanagerVersion), nil
-}
-
-func CleanUpTestDir(path string) {
-	err := os.RemoveAll(path)
-	Expect(err).NotTo(HaveOccurred())
-}
 
-func GetMetrics(sample samples.Sample, kubectl kubernetes.Kubectl, metricsClusterRoleBindingName string) string {
-	By("granting permissions to access the metrics and read the token")
-	out, err := kubectl.Command("create", "clusterrolebinding", metricsClusterRoleBindingName,
-		fmt.Sprintf("--clusterrole=%s-metrics-reader", sample.Name()),
-		fmt.Sprintf("--serviceaccount=%s:%s", kubectl.Namespace(), kubectl.ServiceAccount()))
-	fmt.Println("OUT --", out)
-	Expect(err).NotTo(HaveOccurred())
-
-	By("reading the metrics token")
-	// Filter token query by service account in case more than one exists in a namespace.
-	query := fmt.Sprintf(`{.items[?(@.metadata.annotations.kubernetes\.io/service-account\.name=="%s")].data.token}`,
-		kubectl.ServiceAccount(),
-	)
-	out, err = kubectl.Get(true, "secrets")
-	fmt.Println("OUT --", out)
-	b64Token, err := kubectl.Get(true, "secrets", "-o=jsonpath="+query)
-	fmt.Println("OUT--", b64Token)
-	Expect(err).NotTo(HaveOccurred())
-	token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64Token))
-	Expect(err).NotTo(HaveOccurred())
-	Expect(len(token)).To(BeNumerically(">", 0))
-
-	By("creating a curl pod")
 	cmdOpts := []string{
 		"run", "curl", "--image=curlimages/curl:7.68.0", "--restart=OnFailure", "--",
-		"curl", "-v", "-k", "-H", fmt.Sprintf(`Authorization: Bearer %s`, token),
+		"curl", "-s", "-v", "-k", "-H", fmt.Sprintf(`Authorization: Bearer %s`, token),
 		fmt.Sprintf("https://%s-controller-manager-metrics-service.%s.svc:8443/metrics", sample.Name(), kubectl.Namespace()),
 	}
-	out, err = kubectl.CommandInNamespace(cmdOpts...)
-	fmt.Println("OUT --", out)
-	Expect(err).NotTo(HaveOccurred())
-
-	By("validating that the curl pod is running as expected")
-	verifyCurlUp := func() error {
-		// Validate pod status
-		status, err := kubectl.Get(
-			true,
-			"pods", "curl", "-o", "jsonpath={.status.phase}")
+	out, err := kubectl.CommandInNamespace(cmdOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when creating the curl pod: %w, output: %s", err, out)
+	}
+
+	var status string
+	err = wait.PollImmediate(time.Second, 2*time.Minute, func() (bool, error) {
+		status, err = kubectl.Get(true, "pods", "curl", "-o", "jsonpath={.status.phase}")
 		if err != nil {
-			return err
-		}
-		if status != "Completed" && status != "Succeeded" {
-			return fmt.Errorf("curl pod in %s status", status)
+			return false, nil
 		}
-		return nil
+		return status == "Completed" || status == "Succeeded", nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("curl pod in %q status: %w", status, err)
 	}
-	Eventually(verifyCurlUp, 2*time.Minute, time.Second).Should(Succeed())
 
-	By("validating that the metrics endpoint is serving as expected")
 	var metricsOutput string
-	getCurlLogs := func() string {
+	err = wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
 		metricsOutput, err = kubectl.Logs(true, "curl")
-		ExpectWithOffset(1, err).NotTo(HaveOccurred())
-		return metricsOutput
+		if err != nil {
+			return false, nil
+		}
+		return strings.Contains(metricsOutput, "< HTTP/2 200"), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("metrics endpoint did not respond with 200, curl output: %s", metricsOutput)
+	}
+
+	metrics, err := ParseMetrics(ExtractCurlBody(metricsOutput))
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when parsing the metrics: %w", err)
 	}
-	Eventually(getCurlLogs, time.Minute, time.Second).Should(ContainSubstring("< HTTP/2 200"))
 
-	return metricsOutput
+	return metrics, nil
 }
 
+// CleanUpMetrics deletes the curl pod and ClusterRoleBinding created by GetMetrics.
+// Resources that do not exist are ignored so it is safe to call after a partial setup.
 func CleanUpMetrics(kubectl kubernetes.Kubectl, metricsClusterRoleBindingName string) error {
-	_, err := kubectl.Delete(true, "pod", "curl")
+	err := deleteIgnoreNotFound(kubectl, true, "pod", "curl")
 	if err != nil {
 		return fmt.Errorf("encountered an error when deleting the metrics pod: %w", err)
 	}
 
-	_, err = kubectl.Delete(false, "clusterrolebinding", metricsClusterRoleBindingName)
+	err = deleteIgnoreNotFound(kubectl, false, "clusterrolebinding", metricsClusterRoleBindingName)
 	if err != nil {
 		return fmt.Errorf("encountered an error when deleting the metrics clusterrolebinding: %w", err)
 	}
@@ -290,13 +327,7 @@ func CleanUpMetrics(kubectl kubernetes.Kubectl, metricsClusterRoleBindingName st
 }
 
 func CreateCustomResource(sample samples.Sample, kubectl kubernetes.Kubectl) error {
-	sampleFile := filepath.Join(sample.CommandContext().Dir(),
-		sample.Name(),
-		"config",
-		"samples",
-		fmt.Sprintf("%s_%s_%s.yaml", sample.GVK().Group, sample.GVK().Version, strings.ToLower(sample.GVK().Kind)))
-
-	_, err := kubectl.Apply(true, "-f", sampleFile)
+	_, err := kubectl.Apply(true, "-f", samples.CustomResourceSamplePath(sample))
 	return err
 }
 
@@ -345,8 +376,7 @@ func LoadImageToKindCluster(cc command.CommandContext, image string) error {
 	if v, ok := os.LookupEnv("KIND_CLUSTER"); ok {
 		cluster = v
 	}
-	kindOptions := []string{"load", "docker-image", image, "--name", cluster}
-	cmd := exec.Command("kind", kindOptions...)
-	_, err := cc.Run(cmd)
+	kind := NewKindCluster(WithKindCommandContext(cc), WithKindClusterName(cluster))
+	_, err := kind.LoadImage(image)
 	return err
 }
@@ -0,0 +1,252 @@
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/command"
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+)
+
+// KindCluster manages the lifecycle of a KinD cluster. Clusters created
+// with Create write their kubeconfig to a dedicated file so the user's
+// default kubeconfig is never modified.
+//
+// When running specs in parallel the cluster can be created in the first function
+// of a Ginkgo SynchronizedBeforeSuite, returning the path from Kubeconfig so that
+// every process can construct a KubectlUtil using kubernetes.WithKubeconfig.
+type KindCluster struct {
+	name           string
+	binary         string
+	containerTool  ContainerTool
+	commandContext command.CommandContext
+	kubeconfigDir  string
+	kubeconfig     string
+}
+
+type KindClusterOption func(kc *KindCluster)
+
+// WithKindBinary sets the binary that is used to execute kind commands
+func WithKindBinary(binary string) KindClusterOption {
+	return func(kc *KindCluster) {
+		kc.binary = binary
+	}
+}
+
+// WithKindCommandContext sets the CommandContext that is used to execute kind commands
+func WithKindCommandContext(cc command.CommandContext) KindClusterOption {
+	return func(kc *KindCluster) {
+		kc.commandContext = cc
+	}
+}
+
+// WithKindContainerTool sets the ContainerTool images are loaded from. Images
+// built with tools other than docker are loaded through an image archive.
+func WithKindContainerTool(tool ContainerTool) KindClusterOption {
+	return func(kc *KindCluster) {
+		kc.containerTool = tool
+	}
+}
+
+// WithKindClusterName sets the name of the cluster. This can be used
+// to manage an already existing cluster without calling Create.
+func WithKindClusterName(name string) KindClusterOption {
+	return func(kc *KindCluster) {
+		kc.name = name
+	}
+}
+
+func NewKindCluster(opts ...KindClusterOption) *KindCluster {
+	kc := &KindCluster{
+		name:           "kind",
+		binary:         "kind",
+		containerTool:  Docker,
+		commandContext: command.NewGenericCommandContext(),
+	}
+
+	for _, opt := range opts {
+		opt(kc)
+	}
+
+	return kc
+}
+
+// Name returns the name of the cluster
+func (kc *KindCluster) Name() string {
+	return kc.name
+}
+
+// Create creates a new cluster with the given name. If nodeImage is not
+// empty it is used as the node image (i.e kindest/node:v1.24.0) to pin the
+// Kubernetes version. If config is not empty it is used as the contents
+// of the kind configuration file.
+func (kc *KindCluster) Create(name string, nodeImage string, config string) (err error) {
+	kc.name = name
+
+	kubeconfig, err := kc.kubeconfigPath()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			kc.removeKubeconfigDir()
+		}
+	}()
+
+	options := []string{"create", "cluster", "--name", kc.name, "--kubeconfig", kubeconfig}
+
+	if nodeImage != "" {
+		options = append(options, "--image", nodeImage)
+	}
+
+	if config != "" {
+		configPath := filepath.Join(kc.kubeconfigDir, "kind-config.yaml")
+		err = os.WriteFile(configPath, []byte(config), 0644)
+		if err != nil {
+			return fmt.Errorf("encountered an error when writing the kind config: %w", err)
+		}
+		options = append(options, "--config", configPath)
+	}
+
+	cmd := exec.Command(kc.binary, options...)
+	out, err := kc.commandContext.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when creating kind cluster %q: %w, output: %s", kc.name, err, string(out))
+	}
+
+	kc.kubeconfig = kubeconfig
+	return nil
+}
+
+// Delete deletes the cluster and any kubeconfig written for it
+func (kc *KindCluster) Delete() error {
+	cmd := exec.Command(kc.binary, "delete", "cluster", "--name", kc.name)
+	out, err := kc.commandContext.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when deleting kind cluster %q: %w, output: %s", kc.name, err, string(out))
+	}
+
+	err = kc.removeKubeconfigDir()
+	if err != nil {
+		return fmt.Errorf("encountered an error when removing the kubeconfig: %w", err)
+	}
+
+	return nil
+}
+
+// LoadImage loads a locally built image onto the nodes of the cluster
+func (kc *KindCluster) LoadImage(image string) (string, error) {
+	if kc.containerTool != Docker {
+		if err := loadImageThroughArchive(kc, kc.commandContext, kc.containerTool, image); err != nil {
+			return "", err
+		}
+		return image, nil
+	}
+
+	cmd := exec.Command(kc.binary, "load", "docker-image", image, "--name", kc.name)
+	out, err := kc.commandContext.Run(cmd)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when loading image %q to kind cluster %q: %w, output: %s", image, kc.name, err, string(out))
+	}
+
+	return image, nil
+}
+
+// LoadImageArchive loads an image archive onto the nodes of the cluster
+func (kc *KindCluster) LoadImageArchive(path string) error {
+	cmd := exec.Command(kc.binary, "load", "image-archive", path, "--name", kc.name)
+	out, err := kc.commandContext.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when loading image archive %q to kind cluster %q: %w, output: %s", path, kc.name, err, string(out))
+	}
+
+	return nil
+}
+
+// Kubeconfig returns the path to a kubeconfig file for the cluster. For
+// clusters not created with Create the kubeconfig is retrieved from kind.
+func (kc *KindCluster) Kubeconfig() (string, error) {
+	if kc.kubeconfig != "" {
+		return kc.kubeconfig, nil
+	}
+
+	// Only stdout is the kubeconfig, kind writes warnings to stderr
+	var stderr bytes.Buffer
+	cmd := exec.Command(kc.binary, "get", "kubeconfig", "--name", kc.name)
+	cmd.Dir = kc.commandContext.Dir()
+	cmd.Env = append(os.Environ(), kc.commandContext.Env()...)
+	cmd.Stderr = &stderr
+
+	fmt.Println("Running command:", strings.Join(cmd.Args, " "))
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when getting the kubeconfig for kind cluster %q: %w, output: %s", kc.name, err, stderr.String())
+	}
+
+	kubeconfig, err := kc.kubeconfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.WriteFile(kubeconfig, out, 0600)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when writing the kubeconfig: %w", err)
+	}
+
+	kc.kubeconfig = kubeconfig
+	return kc.kubeconfig, nil
+}
+
+// Kubectl returns a KubectlUtil that is configured to use the cluster
+func (kc *KindCluster) Kubectl(opts ...kubernetes.KubectlUtilOptions) (*kubernetes.KubectlUtil, error) {
+	kubeconfig, err := kc.Kubeconfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]kubernetes.KubectlUtilOptions{kubernetes.WithKubeconfig(kubeconfig)}, opts...)
+	return kubernetes.NewKubectlUtil(opts...), nil
+}
+
+// ExportLogs exports the logs of the cluster nodes to the given directory
+func (kc *KindCluster) ExportLogs(dir string) error {
+	cmd := exec.Command(kc.binary, "export", "logs", dir, "--name", kc.name)
+	out, err := kc.commandContext.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when exporting logs for kind cluster %q: %w, output: %s", kc.name, err, string(out))
+	}
+
+	return nil
+}
+
+func (kc *KindCluster) kubeconfigPath() (string, error) {
+	if kc.kubeconfigDir == "" {
+		dir, err := os.MkdirTemp("", "kind-"+kc.name+"-")
+		if err != nil {
+			return "", fmt.Errorf("encountered an error when creating a directory for the kubeconfig: %w", err)
+		}
+		kc.kubeconfigDir = dir
+	}
+
+	return filepath.Join(kc.kubeconfigDir, "kubeconfig"), nil
+}
+
+// removeKubeconfigDir removes the directory holding the kubeconfig and kind config, if any
+func (kc *KindCluster) removeKubeconfigDir() error {
+	if kc.kubeconfigDir == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(kc.kubeconfigDir)
+	if err != nil {
+		return err
+	}
+
+	kc.kubeconfigDir = ""
+	kc.kubeconfig = ""
+	return nil
+}
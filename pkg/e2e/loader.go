@@ -0,0 +1,220 @@
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/command"
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+)
+
+// ErrNoClusterImageLoader is returned when a ClusterImageLoader
+// could not be detected for the current kube context
+var ErrNoClusterImageLoader = errors.New("no image loader detected for the current kube context")
+
+// ClusterImageLoader makes a locally built image available to a cluster
+type ClusterImageLoader interface {
+	// LoadImage makes the image available to the cluster and returns
+	// the image reference that should be used when deploying to the cluster
+	LoadImage(image string) (string, error)
+}
+
+// ClusterImageArchiveLoader loads an image archive onto a cluster
+type ClusterImageArchiveLoader interface {
+	// LoadImageArchive loads the image archive at the given path onto the cluster
+	LoadImageArchive(path string) error
+}
+
+var _ ClusterImageLoader = &KindCluster{}
+var _ ClusterImageArchiveLoader = &KindCluster{}
+var _ ClusterImageArchiveLoader = &K3dImageLoader{}
+var _ ClusterImageArchiveLoader = &MinikubeImageLoader{}
+
+// loadImageThroughArchive saves the image to a temporary archive with
+// the ContainerTool and loads that archive onto the cluster
+func loadImageThroughArchive(loader ClusterImageArchiveLoader, cc command.CommandContext, tool ContainerTool, image string) error {
+	dir, err := os.MkdirTemp("", "image-archive-")
+	if err != nil {
+		return fmt.Errorf("encountered an error when creating a directory for the image archive: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "image.tar")
+	err = SaveImageArchive(cc, tool, image, archive, DockerArchive)
+	if err != nil {
+		return err
+	}
+
+	return loader.LoadImageArchive(archive)
+}
+
+// K3dImageLoader loads images onto a k3d cluster
+type K3dImageLoader struct {
+	cluster        string
+	containerTool  ContainerTool
+	commandContext command.CommandContext
+}
+
+// NewK3dImageLoader creates a new K3dImageLoader for the given k3d cluster
+func NewK3dImageLoader(cc command.CommandContext, cluster string, opts ...ImageOption) *K3dImageLoader {
+	return &K3dImageLoader{
+		cluster:        cluster,
+		containerTool:  newImageOptions(opts...).containerTool,
+		commandContext: cc,
+	}
+}
+
+func (kil *K3dImageLoader) LoadImage(image string) (string, error) {
+	if kil.containerTool != Docker {
+		return image, loadImageThroughArchive(kil, kil.commandContext, kil.containerTool, image)
+	}
+
+	return image, kil.LoadImageArchive(image)
+}
+
+// LoadImageArchive loads an image archive onto the cluster. k3d also
+// accepts the name of an image in the local docker daemon.
+func (kil *K3dImageLoader) LoadImageArchive(path string) error {
+	cmd := exec.Command("k3d", "image", "import", path, "--cluster", kil.cluster)
+	out, err := kil.commandContext.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when loading %q to k3d cluster %q: %w, output: %s", path, kil.cluster, err, string(out))
+	}
+
+	return nil
+}
+
+// MinikubeImageLoader loads images onto a minikube cluster
+type MinikubeImageLoader struct {
+	profile        string
+	containerTool  ContainerTool
+	commandContext command.CommandContext
+}
+
+// NewMinikubeImageLoader creates a new MinikubeImageLoader for the given minikube profile
+func NewMinikubeImageLoader(cc command.CommandContext, profile string, opts ...ImageOption) *MinikubeImageLoader {
+	return &MinikubeImageLoader{
+		profile:        profile,
+		containerTool:  newImageOptions(opts...).containerTool,
+		commandContext: cc,
+	}
+}
+
+func (mil *MinikubeImageLoader) LoadImage(image string) (string, error) {
+	if mil.containerTool != Docker {
+		return image, loadImageThroughArchive(mil, mil.commandContext, mil.containerTool, image)
+	}
+
+	return image, mil.LoadImageArchive(image)
+}
+
+// LoadImageArchive loads an image archive onto the cluster. minikube also
+// accepts the name of an image in the local docker daemon.
+func (mil *MinikubeImageLoader) LoadImageArchive(path string) error {
+	cmd := exec.Command("minikube", "image", "load", path, "--profile", mil.profile)
+	out, err := mil.commandContext.Run(cmd)
+	if err != nil {
+		return fmt.Errorf("encountered an error when loading %q to minikube profile %q: %w, output: %s", path, mil.profile, err, string(out))
+	}
+
+	return nil
+}
+
+// RegistryImageLoader makes images available to a cluster by pushing
+// them to a registry, typically one running locally at host:port
+type RegistryImageLoader struct {
+	registry       string
+	containerTool  ContainerTool
+	commandContext command.CommandContext
+}
+
+// NewRegistryImageLoader creates a new RegistryImageLoader that pushes to the registry at the given host:port
+func NewRegistryImageLoader(cc command.CommandContext, registry string, opts ...ImageOption) *RegistryImageLoader {
+	return &RegistryImageLoader{
+		registry:       registry,
+		containerTool:  newImageOptions(opts...).containerTool,
+		commandContext: cc,
+	}
+}
+
+func (ril *RegistryImageLoader) LoadImage(image string) (string, error) {
+	remoteImage := ril.registry + "/" + stripRegistry(image)
+
+	cmd := exec.Command(string(ril.containerTool), "tag", image, remoteImage)
+	out, err := ril.commandContext.Run(cmd)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when tagging image %q as %q: %w, output: %s", image, remoteImage, err, string(out))
+	}
+
+	cmd = exec.Command(string(ril.containerTool), "push", remoteImage)
+	out, err = ril.commandContext.Run(cmd)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when pushing image %q: %w, output: %s", remoteImage, err, string(out))
+	}
+
+	return remoteImage, nil
+}
+
+// stripRegistry removes the registry host, if any, from an image reference
+func stripRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[1]
+	}
+
+	return image
+}
+
+// ImageRegistryEnv is the environment variable that DetectClusterImageLoader reads the
+// host:port of a registry to push images to from, when WithImageRegistry is not set
+const ImageRegistryEnv = "IMAGE_REGISTRY"
+
+// DetectClusterImageLoader returns a ClusterImageLoader based on the current kube
+// context. A RegistryImageLoader is returned if a registry is set with WithImageRegistry
+// or the IMAGE_REGISTRY environment variable. Otherwise kind, k3d and minikube clusters
+// are detected and ErrNoClusterImageLoader is returned if the context does not match any of them.
+func DetectClusterImageLoader(kubectl kubernetes.Kubectl, opts ...ImageOption) (ClusterImageLoader, error) {
+	io := newImageOptions(opts...)
+
+	registry := io.registry
+	if registry == "" {
+		registry = os.Getenv(ImageRegistryEnv)
+	}
+	if registry != "" {
+		return NewRegistryImageLoader(kubectl.CommandContext(), registry, opts...), nil
+	}
+
+	out, err := kubectl.Command("config", "current-context")
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when getting the current context: %w", err)
+	}
+	kubectx := strings.TrimSpace(out)
+
+	switch {
+	case strings.HasPrefix(kubectx, "kind-"):
+		return NewKindCluster(
+			WithKindCommandContext(kubectl.CommandContext()),
+			WithKindClusterName(strings.TrimPrefix(kubectx, "kind-")),
+			WithKindContainerTool(io.containerTool),
+		), nil
+	case strings.HasPrefix(kubectx, "k3d-"):
+		return NewK3dImageLoader(kubectl.CommandContext(), strings.TrimPrefix(kubectx, "k3d-"), opts...), nil
+	}
+
+	// minikube names the context after the profile and records itself as the provider
+	provider, err := kubectl.Command("config", "view", "--minify",
+		"-o", "jsonpath={.contexts[0].context.extensions[?(@.name==\"context_info\")].extension.provider}")
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when getting the context provider: %w", err)
+	}
+
+	if kubectx == "minikube" || strings.TrimSpace(provider) == "minikube.sigs.k8s.io" {
+		return NewMinikubeImageLoader(kubectl.CommandContext(), kubectx, opts...), nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrNoClusterImageLoader, kubectx)
+}
@@ -0,0 +1,179 @@
+package e2e
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ManifestSource resolves the manifests for a given version of a dependency
+// to a location that can be consumed by `kubectl apply -f`
+type ManifestSource interface {
+	// Resolve returns a file path or URL containing the manifests for the given version
+	Resolve(version string) (string, error)
+}
+
+// URLManifestSource is a ManifestSource that serves manifests from a remote URL
+type URLManifestSource struct {
+	urlTemplate string
+}
+
+// NewURLManifestSource creates a new URLManifestSource. The urlTemplate
+// should contain a single `%s` verb that will be replaced with the version
+func NewURLManifestSource(urlTemplate string) *URLManifestSource {
+	return &URLManifestSource{
+		urlTemplate: urlTemplate,
+	}
+}
+
+func (ums *URLManifestSource) Resolve(version string) (string, error) {
+	return formatManifestTemplate(ums.urlTemplate, version), nil
+}
+
+// FileManifestSource is a ManifestSource that serves manifests from the local filesystem
+type FileManifestSource struct {
+	pathTemplate string
+}
+
+// NewFileManifestSource creates a new FileManifestSource. The pathTemplate
+// should contain a single `%s` verb that will be replaced with the version
+func NewFileManifestSource(pathTemplate string) *FileManifestSource {
+	return &FileManifestSource{
+		pathTemplate: pathTemplate,
+	}
+}
+
+func (fms *FileManifestSource) Resolve(version string) (string, error) {
+	path := formatManifestTemplate(fms.pathTemplate, version)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("encountered an error when checking manifest file %q: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// EmbeddedManifestSource is a ManifestSource that serves manifests from an
+// fs.FS, typically one populated with the `go:embed` directive. Since kubectl
+// can not read from an fs.FS directly the manifests are written to a
+// temporary directory when they are resolved, keeping their path within
+// the fs.FS so the manifests of different versions do not overwrite each other.
+//
+// This package does not embed any manifests itself, the fs.FS has to be
+// provided by the test suite, i.e. to install dependencies without network access.
+type EmbeddedManifestSource struct {
+	fsys         fs.FS
+	pathTemplate string
+
+	mu       sync.Mutex
+	tempDir  string
+	resolved map[string]string
+}
+
+// NewEmbeddedManifestSource creates a new EmbeddedManifestSource. The pathTemplate
+// should contain a single `%s` verb that will be replaced with the version
+func NewEmbeddedManifestSource(fsys fs.FS, pathTemplate string) *EmbeddedManifestSource {
+	return &EmbeddedManifestSource{
+		fsys:         fsys,
+		pathTemplate: pathTemplate,
+		resolved:     make(map[string]string),
+	}
+}
+
+func (ems *EmbeddedManifestSource) Resolve(version string) (string, error) {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+
+	if path, ok := ems.resolved[version]; ok {
+		return path, nil
+	}
+
+	name := formatManifestTemplate(ems.pathTemplate, version)
+	b, err := fs.ReadFile(ems.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when reading embedded manifest %q: %w", name, err)
+	}
+
+	if ems.tempDir == "" {
+		ems.tempDir, err = os.MkdirTemp("", "manifests-")
+		if err != nil {
+			return "", fmt.Errorf("encountered an error when creating a temporary directory: %w", err)
+		}
+	}
+
+	path := filepath.Join(ems.tempDir, filepath.FromSlash(name))
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when creating the directory for embedded manifest %q: %w", name, err)
+	}
+
+	err = os.WriteFile(path, b, 0644)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when writing embedded manifest %q: %w", name, err)
+	}
+
+	ems.resolved[version] = path
+	return path, nil
+}
+
+// Cleanup removes any manifests that were written to disk when resolving
+func (ems *EmbeddedManifestSource) Cleanup() error {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+
+	if ems.tempDir == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(ems.tempDir)
+	if err != nil {
+		return fmt.Errorf("encountered an error when removing embedded manifests: %w", err)
+	}
+
+	ems.tempDir = ""
+	ems.resolved = make(map[string]string)
+	return nil
+}
+
+func formatManifestTemplate(template string, version string) string {
+	if !strings.Contains(template, "%s") {
+		return template
+	}
+
+	return fmt.Sprintf(template, version)
+}
+
+// ManifestOptions configures where the manifests for a dependency are sourced
+// from and which version of them is used
+type ManifestOptions struct {
+	source  ManifestSource
+	version string
+}
+
+type ManifestOption func(mo *ManifestOptions)
+
+// WithManifestSource sets the ManifestSource the manifests are resolved from
+func WithManifestSource(source ManifestSource) ManifestOption {
+	return func(mo *ManifestOptions) {
+		mo.source = source
+	}
+}
+
+// WithVersion sets the version of the manifests that should be resolved
+func WithVersion(version string) ManifestOption {
+	return func(mo *ManifestOptions) {
+		mo.version = version
+	}
+}
+
+func newManifestOptions(opts ...ManifestOption) *ManifestOptions {
+	mo := &ManifestOptions{}
+
+	for _, opt := range opts {
+		opt(mo)
+	}
+
+	return mo
+}
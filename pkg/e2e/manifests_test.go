@@ -0,0 +1,49 @@
+package e2e
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedManifestSourceResolve(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manifests/v1.0.0/cert-manager.yaml": {Data: []byte("version: v1.0.0\n")},
+		"manifests/v1.1.0/cert-manager.yaml": {Data: []byte("version: v1.1.0\n")},
+	}
+	ems := NewEmbeddedManifestSource(fsys, "manifests/%s/cert-manager.yaml")
+	defer ems.Cleanup()
+
+	paths := map[string]string{}
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		path, err := ems.Resolve(version)
+		if err != nil {
+			t.Fatalf("unexpected error resolving %s: %v", version, err)
+		}
+		paths[version] = path
+	}
+
+	if paths["v1.0.0"] == paths["v1.1.0"] {
+		t.Fatalf("expected each version to be written to its own file, got %s", paths["v1.0.0"])
+	}
+	for version, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "version: "+version+"\n" {
+			t.Errorf("got %q for %s, want the manifest of that version", b, version)
+		}
+	}
+
+	if _, err := ems.Resolve("v2.0.0"); err == nil {
+		t.Errorf("expected an error for a version that is not embedded")
+	}
+
+	if err := ems.Cleanup(); err != nil {
+		t.Fatalf("unexpected error cleaning up: %v", err)
+	}
+	if _, err := os.Stat(paths["v1.0.0"]); !os.IsNotExist(err) {
+		t.Errorf("expected the resolved manifests to be removed, got %v", err)
+	}
+}
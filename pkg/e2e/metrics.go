@@ -0,0 +1,197 @@
+package e2e
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Metrics are the metric families served by a metrics endpoint keyed by the metric name
+type Metrics map[string]*dto.MetricFamily
+
+// MetricLabels are used to select the series of a metric
+type MetricLabels map[string]string
+
+// ParseMetrics parses a body in the Prometheus text exposition format
+func ParseMetrics(body string) (Metrics, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when parsing metrics: %w", err)
+	}
+
+	return Metrics(families), nil
+}
+
+// ExtractCurlBody removes the lines written by `curl -v` from the
+// output of curl, leaving only the response body
+func ExtractCurlBody(output string) string {
+	var body []string
+	for _, line := range strings.Split(output, "\n") {
+		if isCurlVerboseLine(line) {
+			continue
+		}
+		body = append(body, line)
+	}
+
+	return strings.TrimSpace(strings.Join(body, "\n")) + "\n"
+}
+
+func isCurlVerboseLine(line string) bool {
+	for _, prefix := range []string{"* ", "> ", "< ", "{ ", "} "} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return line == "*" || line == ">" || line == "<"
+}
+
+// Value returns the sum of the values of all series of the metric that have the given
+// labels. For histograms and summaries the sample count is used as the value of a series.
+func (m Metrics) Value(name string, labels MetricLabels) (float64, error) {
+	family, ok := m[name]
+	if !ok {
+		return 0, fmt.Errorf("metric %q not found", name)
+	}
+
+	var value float64
+	var found bool
+	for _, metric := range family.GetMetric() {
+		if !hasLabels(metric, labels) {
+			continue
+		}
+
+		found = true
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			value += metric.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			value += metric.GetGauge().GetValue()
+		case dto.MetricType_HISTOGRAM:
+			value += float64(metric.GetHistogram().GetSampleCount())
+		case dto.MetricType_SUMMARY:
+			value += float64(metric.GetSummary().GetSampleCount())
+		default:
+			value += metric.GetUntyped().GetValue()
+		}
+	}
+
+	if !found {
+		return 0, fmt.Errorf("metric %q has no series with labels %v", name, labels)
+	}
+
+	return value, nil
+}
+
+func hasLabels(metric *dto.Metric, labels MetricLabels) bool {
+	for name, value := range labels {
+		var matched bool
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == name && pair.GetValue() == value {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HaveMetric succeeds if the actual Metrics, or a string in the Prometheus text
+// exposition format, contains the named metric with series matching the labels
+// and the summed value of those series satisfies the matcher. If matcher is nil
+// only the presence of the series is checked.
+func HaveMetric(name string, labels MetricLabels, matcher types.GomegaMatcher) types.GomegaMatcher {
+	return &haveMetricMatcher{
+		name:    name,
+		labels:  labels,
+		matcher: matcher,
+	}
+}
+
+type haveMetricMatcher struct {
+	name    string
+	labels  MetricLabels
+	matcher types.GomegaMatcher
+
+	value    float64
+	notFound error
+}
+
+func (hmm *haveMetricMatcher) Match(actual interface{}) (bool, error) {
+	var metrics Metrics
+	switch m := actual.(type) {
+	case Metrics:
+		metrics = m
+	case string:
+		var err error
+		metrics, err = ParseMetrics(m)
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("HaveMetric matcher expects Metrics or a string. Got:\n%s", format.Object(actual, 1))
+	}
+
+	hmm.value, hmm.notFound = metrics.Value(hmm.name, hmm.labels)
+	if hmm.notFound != nil {
+		return false, nil
+	}
+
+	if hmm.matcher == nil {
+		return true, nil
+	}
+
+	return hmm.matcher.Match(hmm.value)
+}
+
+func (hmm *haveMetricMatcher) FailureMessage(actual interface{}) string {
+	if hmm.notFound != nil {
+		return fmt.Sprintf("Expected metrics to contain %s, but %v\nAvailable metrics:\n%s",
+			hmm.describe(), hmm.notFound, metricNames(actual))
+	}
+
+	return fmt.Sprintf("Expected the value of %s to match, but:\n%s", hmm.describe(), hmm.matcher.FailureMessage(hmm.value))
+}
+
+func (hmm *haveMetricMatcher) NegatedFailureMessage(actual interface{}) string {
+	if hmm.matcher == nil {
+		return fmt.Sprintf("Expected metrics not to contain %s", hmm.describe())
+	}
+
+	return fmt.Sprintf("Expected the value of %s not to match, but:\n%s", hmm.describe(), hmm.matcher.NegatedFailureMessage(hmm.value))
+}
+
+func (hmm *haveMetricMatcher) describe() string {
+	if len(hmm.labels) == 0 {
+		return fmt.Sprintf("metric %q", hmm.name)
+	}
+
+	return fmt.Sprintf("metric %q with labels %v", hmm.name, hmm.labels)
+}
+
+func metricNames(actual interface{}) string {
+	metrics, ok := actual.(Metrics)
+	if !ok {
+		s, _ := actual.(string)
+		metrics, _ = ParseMetrics(s)
+	}
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, "\t"+name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, "\n")
+}
@@ -0,0 +1,95 @@
+package e2e
+
+import (
+	"testing"
+)
+
+const testMetrics = `# HELP controller_runtime_reconcile_total Total number of reconciliations per controller
+# TYPE controller_runtime_reconcile_total counter
+controller_runtime_reconcile_total{controller="memcached",result="error"} 1
+controller_runtime_reconcile_total{controller="memcached",result="success"} 4
+controller_runtime_reconcile_total{controller="other",result="success"} 2
+# HELP workqueue_depth Current depth of workqueue
+# TYPE workqueue_depth gauge
+workqueue_depth{name="memcached"} 0
+# HELP controller_runtime_reconcile_time_seconds Length of time per reconciliation per controller
+# TYPE controller_runtime_reconcile_time_seconds histogram
+controller_runtime_reconcile_time_seconds_bucket{controller="memcached",le="0.1"} 3
+controller_runtime_reconcile_time_seconds_bucket{controller="memcached",le="+Inf"} 5
+controller_runtime_reconcile_time_seconds_sum{controller="memcached"} 0.42
+controller_runtime_reconcile_time_seconds_count{controller="memcached"} 5
+untyped_metric 7
+`
+
+func TestParseMetrics(t *testing.T) {
+	metrics, err := ParseMetrics(testMetrics)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		metric  string
+		labels  MetricLabels
+		want    float64
+		wantErr bool
+	}{
+		{name: "counter summed over all series", metric: "controller_runtime_reconcile_total", want: 7},
+		{name: "counter selected by one label", metric: "controller_runtime_reconcile_total", labels: MetricLabels{"controller": "memcached"}, want: 5},
+		{name: "counter selected by all labels", metric: "controller_runtime_reconcile_total", labels: MetricLabels{"controller": "memcached", "result": "error"}, want: 1},
+		{name: "gauge", metric: "workqueue_depth", labels: MetricLabels{"name": "memcached"}, want: 0},
+		{name: "histogram uses the sample count", metric: "controller_runtime_reconcile_time_seconds", labels: MetricLabels{"controller": "memcached"}, want: 5},
+		{name: "untyped without HELP and TYPE lines", metric: "untyped_metric", want: 7},
+		{name: "no series with the labels", metric: "controller_runtime_reconcile_total", labels: MetricLabels{"controller": "missing"}, wantErr: true},
+		{name: "missing metric", metric: "missing_total", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := metrics.Value(tt.metric, tt.labels)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got value %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMetricsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "value is not a number", body: "metric_total abc\n"},
+		{name: "conflicting TYPE lines", body: "# TYPE metric_total counter\n# TYPE metric_total gauge\nmetric_total 1\n"},
+		{name: "unterminated label set", body: "metric_total{controller=\"memcached\" 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMetrics(tt.body); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestExtractCurlBody(t *testing.T) {
+	output := "* Connected to localhost\n> GET /metrics HTTP/1.1\n< HTTP/1.1 200 OK\n<\n{ [5 bytes data]\n" + testMetrics
+
+	metrics, err := ParseMetrics(ExtractCurlBody(output))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := metrics["workqueue_depth"]; !ok {
+		t.Errorf("expected workqueue_depth to be parsed from the curl output")
+	}
+}
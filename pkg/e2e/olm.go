@@ -0,0 +1,305 @@
+package e2e
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/command"
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbutil "sigs.k8s.io/kubebuilder/v3/pkg/plugin/util"
+)
+
+// OLMOptions configures how operator-sdk is run when managing OLM, bundles and scorecard
+type OLMOptions struct {
+	binary     string
+	olmVersion string
+	timeout    time.Duration
+	extraArgs  []string
+	suites     []string
+}
+
+type OLMOption func(oo *OLMOptions)
+
+// WithOperatorSDKBinary sets the operator-sdk binary that is used
+func WithOperatorSDKBinary(binary string) OLMOption {
+	return func(oo *OLMOptions) {
+		oo.binary = binary
+	}
+}
+
+// WithOLMVersion sets the version of OLM that is installed
+func WithOLMVersion(version string) OLMOption {
+	return func(oo *OLMOptions) {
+		oo.olmVersion = version
+	}
+}
+
+// WithOLMTimeout sets how long to wait for OLM operations to complete
+func WithOLMTimeout(timeout time.Duration) OLMOption {
+	return func(oo *OLMOptions) {
+		oo.timeout = timeout
+	}
+}
+
+// WithExtraOLMArgs sets extra arguments that are passed to operator-sdk,
+// i.e "--index-image" or "--install-mode" when running a bundle
+func WithExtraOLMArgs(args ...string) OLMOption {
+	return func(oo *OLMOptions) {
+		oo.extraArgs = make([]string, len(args))
+		copy(oo.extraArgs, args)
+	}
+}
+
+// WithSuites sets the optional validator suites ValidateBundleWithOptions runs,
+// i.e "operatorframework" or "community"
+func WithSuites(suites ...string) OLMOption {
+	return func(oo *OLMOptions) {
+		oo.suites = make([]string, len(suites))
+		copy(oo.suites, suites)
+	}
+}
+
+func newOLMOptions(opts ...OLMOption) *OLMOptions {
+	oo := &OLMOptions{
+		binary:  "operator-sdk",
+		timeout: 5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(oo)
+	}
+
+	return oo
+}
+
+// InstallOLM installs OLM with `operator-sdk olm install`
+func InstallOLM(kubectl kubernetes.Kubectl, opts ...OLMOption) error {
+	oo := newOLMOptions(opts...)
+
+	args := []string{"olm", "install", "--timeout", oo.timeout.String()}
+	if oo.olmVersion != "" {
+		args = append(args, "--version", oo.olmVersion)
+	}
+
+	out, err := runOperatorSDK(kubectl.CommandContext(), kubectl, oo, args...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when installing OLM: %w, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// UninstallOLM uninstalls OLM with `operator-sdk olm uninstall`
+func UninstallOLM(kubectl kubernetes.Kubectl, opts ...OLMOption) error {
+	oo := newOLMOptions(opts...)
+
+	args := []string{"olm", "uninstall", "--timeout", oo.timeout.String()}
+	if oo.olmVersion != "" {
+		args = append(args, "--version", oo.olmVersion)
+	}
+
+	out, err := runOperatorSDK(kubectl.CommandContext(), kubectl, oo, args...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when uninstalling OLM: %w, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// RunBundle installs the bundle image of the sample into the Kubectl namespace with
+// `operator-sdk run bundle` and waits for its ClusterServiceVersion to succeed. If
+// the install fails the error contains the state of the OLM resources in the namespace.
+func RunBundle(sample samples.Sample, kubectl kubernetes.Kubectl, bundleImage string, opts ...OLMOption) error {
+	return runBundle(sample, kubectl, "bundle", bundleImage, opts...)
+}
+
+// RunBundleUpgrade upgrades a bundle installed with RunBundle to the bundle image with
+// `operator-sdk run bundle-upgrade` and waits for the new ClusterServiceVersion to succeed
+func RunBundleUpgrade(sample samples.Sample, kubectl kubernetes.Kubectl, bundleImage string, opts ...OLMOption) error {
+	return runBundle(sample, kubectl, "bundle-upgrade", bundleImage, opts...)
+}
+
+func runBundle(sample samples.Sample, kubectl kubernetes.Kubectl, subcommand string, bundleImage string, opts ...OLMOption) error {
+	oo := newOLMOptions(opts...)
+
+	args := []string{"run", subcommand, bundleImage,
+		"--namespace", kubectl.Namespace(),
+		"--timeout", oo.timeout.String(),
+	}
+	args = append(args, oo.extraArgs...)
+
+	out, err := runOperatorSDK(sample.CommandContext(), kubectl, oo, args...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when running `%s` for %s: %w, output: %s\n%s",
+			subcommand, bundleImage, err, out, describeOLMResources(kubectl))
+	}
+
+	err = WaitForCSVSucceeded(sample, kubectl, oo.timeout)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, describeOLMResources(kubectl))
+	}
+
+	return nil
+}
+
+// CleanupBundle removes the operator installed with RunBundle with `operator-sdk cleanup`
+func CleanupBundle(sample samples.Sample, kubectl kubernetes.Kubectl, opts ...OLMOption) error {
+	oo := newOLMOptions(opts...)
+
+	args := []string{"cleanup", sample.Name(),
+		"--namespace", kubectl.Namespace(),
+		"--timeout", oo.timeout.String(),
+	}
+
+	out, err := runOperatorSDK(sample.CommandContext(), kubectl, oo, args...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when cleaning up %s: %w, output: %s", sample.Name(), err, out)
+	}
+
+	return nil
+}
+
+// WaitForCSVSucceeded waits until every ClusterServiceVersion of the sample in the
+// Kubectl namespace has reached the Succeeded phase. During an upgrade this means
+// waiting until the replaced ClusterServiceVersion has been removed.
+func WaitForCSVSucceeded(sample samples.Sample, kubectl kubernetes.Kubectl, timeout time.Duration) error {
+	var phases map[string]string
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		out, err := kubectl.Get(true, "clusterserviceversions", "--no-headers",
+			"-o", "custom-columns=NAME:.metadata.name,PHASE:.status.phase")
+		if err != nil {
+			return false, nil
+		}
+
+		phases = make(map[string]string)
+		for _, line := range kbutil.GetNonEmptyLines(out) {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || !strings.HasPrefix(fields[0], sample.Name()+".") {
+				continue
+			}
+			phases[fields[0]] = fields[1]
+		}
+
+		if len(phases) == 0 {
+			return false, nil
+		}
+
+		for _, phase := range phases {
+			if phase != "Succeeded" {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("ClusterServiceVersions for %s did not succeed within %s, phases: %v", sample.Name(), timeout, phases)
+	}
+
+	return nil
+}
+
+// describeOLMResources returns a summary of the OLM resources in the
+// Kubectl namespace to help diagnose failed installs
+func describeOLMResources(kubectl kubernetes.Kubectl) string {
+	queries := []struct {
+		resource string
+		jsonPath string
+	}{
+		{
+			resource: "catalogsources",
+			jsonPath: `{range .items[*]}{.metadata.name}: {.status.connectionState.lastObservedState}{"\n"}{end}`,
+		},
+		{
+			resource: "subscriptions",
+			jsonPath: `{range .items[*]}{.metadata.name}: {.status.state} {range .status.conditions[*]}[{.type}={.status}: {.message}] {end}{"\n"}{end}`,
+		},
+		{
+			resource: "installplans",
+			jsonPath: `{range .items[*]}{.metadata.name}: {.status.phase} {range .status.conditions[*]}[{.type}={.status}: {.message}] {end}{"\n"}{end}`,
+		},
+		{
+			resource: "clusterserviceversions",
+			jsonPath: `{range .items[*]}{.metadata.name}: {.status.phase} {.status.reason}: {.status.message}{"\n"}{end}`,
+		},
+	}
+
+	var b strings.Builder
+	b.WriteString("OLM resources in namespace " + kubectl.Namespace() + ":\n")
+	for _, q := range queries {
+		out, err := kubectl.Get(true, q.resource, "-o", "jsonpath="+q.jsonPath)
+		if err != nil {
+			out = fmt.Sprintf("could not get %s: %v", q.resource, err)
+		}
+		fmt.Fprintf(&b, "%s:\n%s\n", q.resource, strings.TrimSpace(out))
+	}
+
+	return b.String()
+}
+
+func runOperatorSDK(cc command.CommandContext, kubectl kubernetes.Kubectl, oo *OLMOptions, args ...string) (string, error) {
+	if kubeconfig := kubernetes.KubeconfigOf(kubectl); kubeconfig != "" {
+		args = append(args, "--kubeconfig", kubeconfig)
+	}
+
+	cmd := exec.Command(oo.binary, args...)
+	out, err := cc.Run(cmd)
+	return string(out), err
+}
+
+// OLMDependency is a Dependency that manages OLM with operator-sdk
+type OLMDependency struct {
+	opts []OLMOption
+}
+
+var _ Dependency = &OLMDependency{}
+
+// NewOLM creates a Dependency that installs OLM so it can be registered with other Dependencies
+func NewOLM(opts ...OLMOption) *OLMDependency {
+	return &OLMDependency{opts: opts}
+}
+
+func (od *OLMDependency) Name() string {
+	return "olm"
+}
+
+func (od *OLMDependency) Install(kubectl kubernetes.Kubectl) error {
+	return InstallOLM(kubectl, od.opts...)
+}
+
+func (od *OLMDependency) WaitReady(kubectl kubernetes.Kubectl) error {
+	oo := newOLMOptions(od.opts...)
+
+	out, err := runOperatorSDK(kubectl.CommandContext(), kubectl, oo, "olm", "status")
+	if err != nil {
+		return fmt.Errorf("encountered an error when checking the OLM status: %w, output: %s", err, out)
+	}
+
+	return nil
+}
+
+func (od *OLMDependency) Uninstall(kubectl kubernetes.Kubectl) error {
+	return UninstallOLM(kubectl, od.opts...)
+}
+
+func (od *OLMDependency) WaitGone(kubectl kubernetes.Kubectl) error {
+	oo := newOLMOptions(od.opts...)
+
+	// `operator-sdk olm uninstall` waits for OLM to be removed, so only confirm the CRDs are gone
+	err := wait.PollImmediate(time.Second, oo.timeout, func() (bool, error) {
+		out, err := kubectl.Get(false, "crd", "clusterserviceversions.operators.coreos.com", "--ignore-not-found", "-o", "name")
+		if err != nil {
+			return false, nil
+		}
+		return strings.TrimSpace(out) == "", nil
+	})
+	if err != nil {
+		return fmt.Errorf("encountered an error when waiting for the OLM CRDs to be removed: %w", err)
+	}
+
+	return nil
+}
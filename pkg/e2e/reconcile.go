@@ -0,0 +1,229 @@
+package e2e
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ReconcileOptions configures what ExpectReconciled checks on a custom resource
+type ReconcileOptions struct {
+	name               string
+	conditions         map[string]string
+	observedGeneration bool
+	ownedResources     []string
+	jsonPaths          map[string]string
+	timeout            time.Duration
+}
+
+type ReconcileOption func(ro *ReconcileOptions)
+
+// WithResourceName sets the name of the custom resource that is checked.
+// Defaults to the name in the scaffolded sample file.
+func WithResourceName(name string) ReconcileOption {
+	return func(ro *ReconcileOptions) {
+		ro.name = name
+	}
+}
+
+// WithCondition expects the custom resource to have a status condition of the given type and status
+func WithCondition(conditionType string, status string) ReconcileOption {
+	return func(ro *ReconcileOptions) {
+		ro.conditions[conditionType] = status
+	}
+}
+
+// WithObservedGeneration expects status.observedGeneration, and the observedGeneration of
+// any status conditions, to match the generation of the custom resource
+func WithObservedGeneration() ReconcileOption {
+	return func(ro *ReconcileOptions) {
+		ro.observedGeneration = true
+	}
+}
+
+// WithOwnedResources expects at least one resource of each of the given types
+// (i.e deployments.apps) to be owned by the custom resource
+func WithOwnedResources(resources ...string) ReconcileOption {
+	return func(ro *ReconcileOptions) {
+		ro.ownedResources = append(ro.ownedResources, resources...)
+	}
+}
+
+// WithJSONPath expects the JSONPath expression (i.e {.status.nodes[0]}),
+// evaluated against the custom resource, to equal the expected value
+func WithJSONPath(jsonPath string, expected string) ReconcileOption {
+	return func(ro *ReconcileOptions) {
+		ro.jsonPaths[jsonPath] = expected
+	}
+}
+
+// WithReconcileTimeout sets how long to wait for the custom resource to be reconciled
+func WithReconcileTimeout(timeout time.Duration) ReconcileOption {
+	return func(ro *ReconcileOptions) {
+		ro.timeout = timeout
+	}
+}
+
+// ExpectReconciled waits until the custom resource created from the sample has been
+// reconciled as described by the options. If the timeout is reached an error is
+// returned describing every expectation that was not met.
+func ExpectReconciled(sample samples.Sample, kubectl kubernetes.Kubectl, opts ...ReconcileOption) error {
+	ro := &ReconcileOptions{
+		conditions: make(map[string]string),
+		jsonPaths:  make(map[string]string),
+		timeout:    2 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(ro)
+	}
+
+	sampleCR, err := samples.LoadCustomResource(sample)
+	if err != nil {
+		return err
+	}
+	if ro.name == "" {
+		ro.name = sampleCR.Name()
+	}
+
+	resource := sampleCR.Resource()
+
+	var diverged []string
+	err = wait.PollImmediate(time.Second, ro.timeout, func() (bool, error) {
+		diverged = checkReconciled(kubectl, resource, ro)
+		return len(diverged) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s %q was not reconciled within %s:\n\t%s", sample.GVK().Kind, ro.name, ro.timeout, strings.Join(diverged, "\n\t"))
+	}
+
+	return nil
+}
+
+// checkReconciled returns a description of each expectation that is not met
+func checkReconciled(kubectl kubernetes.Kubectl, resource string, ro *ReconcileOptions) []string {
+	// Only stdout is read, so kubectl warnings do not end up in the decoded output
+	out, err := kubernetes.OutputInNamespace(kubectl, "get", resource, ro.name, "-o", "json")
+	if err != nil {
+		return []string{fmt.Sprintf("could not get the resource: %v", err)}
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON([]byte(out)); err != nil {
+		return []string{fmt.Sprintf("could not decode the resource: %v", err)}
+	}
+
+	var diverged []string
+	diverged = append(diverged, checkConditions(obj, ro)...)
+
+	if ro.observedGeneration {
+		observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+		if !found || observed != obj.GetGeneration() {
+			diverged = append(diverged, fmt.Sprintf("status.observedGeneration is %d, expected %d", observed, obj.GetGeneration()))
+		}
+	}
+
+	for _, owned := range ro.ownedResources {
+		if err := checkOwned(kubectl, owned, string(obj.GetUID())); err != nil {
+			diverged = append(diverged, err.Error())
+		}
+	}
+
+	jsonPaths := make([]string, 0, len(ro.jsonPaths))
+	for jsonPath := range ro.jsonPaths {
+		jsonPaths = append(jsonPaths, jsonPath)
+	}
+	sort.Strings(jsonPaths)
+
+	for _, jsonPath := range jsonPaths {
+		actual, err := kubernetes.OutputInNamespace(kubectl, "get", resource, ro.name, "-o", "jsonpath="+jsonPath)
+		if err != nil {
+			diverged = append(diverged, fmt.Sprintf("could not evaluate %s: %v", jsonPath, err))
+			continue
+		}
+		if actual != ro.jsonPaths[jsonPath] {
+			diverged = append(diverged, fmt.Sprintf("%s is %q, expected %q", jsonPath, actual, ro.jsonPaths[jsonPath]))
+		}
+	}
+
+	return diverged
+}
+
+func checkConditions(obj *unstructured.Unstructured, ro *ReconcileOptions) []string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+
+	actual := make(map[string]map[string]interface{})
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionType, _ := condition["type"].(string)
+		actual[conditionType] = condition
+	}
+
+	var diverged []string
+
+	conditionTypes := make([]string, 0, len(ro.conditions))
+	for conditionType := range ro.conditions {
+		conditionTypes = append(conditionTypes, conditionType)
+	}
+	sort.Strings(conditionTypes)
+
+	for _, conditionType := range conditionTypes {
+		condition, ok := actual[conditionType]
+		if !ok {
+			diverged = append(diverged, fmt.Sprintf("condition %q is not present", conditionType))
+			continue
+		}
+		if status, _ := condition["status"].(string); status != ro.conditions[conditionType] {
+			diverged = append(diverged, fmt.Sprintf("condition %q has status %q, expected %q (reason: %v, message: %v)",
+				conditionType, status, ro.conditions[conditionType], condition["reason"], condition["message"]))
+		}
+	}
+
+	if ro.observedGeneration {
+		for _, conditionType := range conditionTypes {
+			condition, ok := actual[conditionType]
+			if !ok {
+				continue
+			}
+			observed, found, _ := unstructured.NestedInt64(condition, "observedGeneration")
+			if found && observed != obj.GetGeneration() {
+				diverged = append(diverged, fmt.Sprintf("condition %q has observedGeneration %d, expected %d",
+					conditionType, observed, obj.GetGeneration()))
+			}
+		}
+	}
+
+	return diverged
+}
+
+// checkOwned returns an error if no resource of the given type is owned by the uid
+func checkOwned(kubectl kubernetes.Kubectl, resource string, uid string) error {
+	out, err := kubernetes.OutputInNamespace(kubectl, "get", resource, "-o", "json")
+	if err != nil {
+		return fmt.Errorf("could not list %s: %v", resource, err)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	if err := list.UnmarshalJSON([]byte(out)); err != nil {
+		return fmt.Errorf("could not decode %s: %v", resource, err)
+	}
+
+	for _, item := range list.Items {
+		for _, ref := range item.GetOwnerReferences() {
+			if string(ref.UID) == uid {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("no %s owned by the resource were found", resource)
+}
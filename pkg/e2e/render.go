@@ -0,0 +1,261 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Manifests are the objects rendered from a kustomize directory of a sample
+type Manifests []*unstructured.Unstructured
+
+// RBACRule is a policy rule of a Role or ClusterRole
+type RBACRule struct {
+	APIGroups     []string `json:"apiGroups,omitempty"`
+	Resources     []string `json:"resources,omitempty"`
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	Verbs         []string `json:"verbs"`
+}
+
+// RenderManifests renders config/default of the sample with `kustomize build`, the same
+// manifests `make deploy` applies, so they can be checked without a cluster
+func RenderManifests(sample samples.Sample) (Manifests, error) {
+	return RenderKustomization(sample, samples.DefaultKustomizeDir)
+}
+
+// RenderKustomization renders the kustomize directory of the sample with `kustomize build`
+func RenderKustomization(sample samples.Sample, dir string) (Manifests, error) {
+	out, err := samples.BuildKustomization(sample, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeManifests(out)
+}
+
+// DecodeManifests decodes a multi-document YAML stream, skipping empty documents
+func DecodeManifests(b []byte) (Manifests, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(b)))
+
+	var manifests Manifests
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encountered an error when reading the manifests: %w", err)
+		}
+
+		j, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("encountered an error when decoding a manifest: %w", err)
+		}
+		if len(bytes.TrimSpace(j)) == 0 || string(bytes.TrimSpace(j)) == "null" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(j); err != nil {
+			return nil, fmt.Errorf("encountered an error when decoding a manifest: %w", err)
+		}
+		manifests = append(manifests, obj)
+	}
+
+	return manifests, nil
+}
+
+// Get returns the object of the kind with the name, or nil if there is none
+func (m Manifests) Get(kind string, name string) *unstructured.Unstructured {
+	for _, obj := range m {
+		if obj.GetKind() == kind && obj.GetName() == name {
+			return obj
+		}
+	}
+
+	return nil
+}
+
+// OfKind returns the objects of the kind
+func (m Manifests) OfKind(kind string) Manifests {
+	var objs Manifests
+	for _, obj := range m {
+		if obj.GetKind() == kind {
+			objs = append(objs, obj)
+		}
+	}
+
+	return objs
+}
+
+// RBACRules returns the rules of every Role and ClusterRole, keyed by "<kind>/<name>"
+func (m Manifests) RBACRules() (map[string][]RBACRule, error) {
+	rules := make(map[string][]RBACRule)
+	for _, obj := range m {
+		if obj.GetKind() != "Role" && obj.GetKind() != "ClusterRole" {
+			continue
+		}
+
+		raw, _, _ := unstructured.NestedSlice(obj.Object, "rules")
+		b, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		var roleRules []RBACRule
+		if err := yaml.Unmarshal(b, &roleRules); err != nil {
+			return nil, fmt.Errorf("encountered an error when decoding the rules of %s %q: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		rules[obj.GetKind()+"/"+obj.GetName()] = roleRules
+	}
+
+	return rules, nil
+}
+
+// Allows returns true if the rule grants the verb on the resource in the API group,
+// taking "*" wildcards into account. Rules restricted to resource names are ignored.
+func (r RBACRule) Allows(apiGroup string, resource string, verb string) bool {
+	return len(r.ResourceNames) == 0 &&
+		matchesRBAC(r.APIGroups, apiGroup) &&
+		matchesRBAC(r.Resources, resource) &&
+		matchesRBAC(r.Verbs, verb)
+}
+
+func matchesRBAC(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContainObject succeeds if the actual Manifests contain an object of the kind with the name
+func ContainObject(kind string, name string) types.GomegaMatcher {
+	return &containObjectMatcher{
+		kind: kind,
+		name: name,
+	}
+}
+
+type containObjectMatcher struct {
+	kind string
+	name string
+}
+
+func (com *containObjectMatcher) Match(actual interface{}) (bool, error) {
+	manifests, ok := actual.(Manifests)
+	if !ok {
+		return false, fmt.Errorf("ContainObject matcher expects Manifests. Got:\n%s", format.Object(actual, 1))
+	}
+
+	return manifests.Get(com.kind, com.name) != nil, nil
+}
+
+func (com *containObjectMatcher) FailureMessage(actual interface{}) string {
+	manifests, _ := actual.(Manifests)
+
+	var names []string
+	for _, obj := range manifests.OfKind(com.kind) {
+		names = append(names, obj.GetName())
+	}
+	sort.Strings(names)
+
+	return fmt.Sprintf("Expected the manifests to contain %s %q, found %s objects: %v", com.kind, com.name, com.kind, names)
+}
+
+func (com *containObjectMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected the manifests not to contain %s %q", com.kind, com.name)
+}
+
+// HaveRBACRule succeeds if a Role or ClusterRole in the actual Manifests grants every
+// verb on the resource in the API group. Use "" for the core API group.
+func HaveRBACRule(apiGroup string, resource string, verbs ...string) types.GomegaMatcher {
+	return &haveRBACRuleMatcher{
+		apiGroup: apiGroup,
+		resource: resource,
+		verbs:    verbs,
+	}
+}
+
+type haveRBACRuleMatcher struct {
+	apiGroup string
+	resource string
+	verbs    []string
+	missing  []string
+}
+
+func (hrm *haveRBACRuleMatcher) Match(actual interface{}) (bool, error) {
+	manifests, ok := actual.(Manifests)
+	if !ok {
+		return false, fmt.Errorf("HaveRBACRule matcher expects Manifests. Got:\n%s", format.Object(actual, 1))
+	}
+
+	rules, err := manifests.RBACRules()
+	if err != nil {
+		return false, err
+	}
+
+	hrm.missing = nil
+	for _, roleRules := range rules {
+		if missing := missingVerbs(roleRules, hrm.apiGroup, hrm.resource, hrm.verbs); len(missing) == 0 {
+			return true, nil
+		} else if hrm.missing == nil || len(missing) < len(hrm.missing) {
+			hrm.missing = missing
+		}
+	}
+
+	if hrm.missing == nil {
+		hrm.missing = hrm.verbs
+	}
+
+	return false, nil
+}
+
+// missingVerbs returns the verbs that none of the rules grant on the resource
+func missingVerbs(rules []RBACRule, apiGroup string, resource string, verbs []string) []string {
+	missing := []string{}
+	for _, verb := range verbs {
+		allowed := false
+		for _, rule := range rules {
+			if rule.Allows(apiGroup, resource, verb) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			missing = append(missing, verb)
+		}
+	}
+
+	return missing
+}
+
+func (hrm *haveRBACRuleMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected a Role or ClusterRole to grant %s on %s, but the closest match is missing %s",
+		strings.Join(hrm.verbs, ","), hrm.groupResource(), strings.Join(hrm.missing, ","))
+}
+
+func (hrm *haveRBACRuleMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected no Role or ClusterRole to grant %s on %s", strings.Join(hrm.verbs, ","), hrm.groupResource())
+}
+
+func (hrm *haveRBACRuleMatcher) groupResource() string {
+	if hrm.apiGroup == "" {
+		return hrm.resource
+	}
+
+	return hrm.resource + "." + hrm.apiGroup
+}
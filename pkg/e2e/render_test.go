@@ -0,0 +1,238 @@
+package e2e
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testManifestStream = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: memcached-operator-system
+---
+---
+# only a comment
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: memcached-operator-manager-role
+rules:
+- apiGroups: ["apps"]
+  resources: ["deployments"]
+  verbs: ["get", "list", "watch", "create", "update", "patch", "delete"]
+- apiGroups: ["cache.example.com"]
+  resources: ["*"]
+  verbs: ["*"]
+---
+
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: memcached-operator-leader-election-role
+  namespace: memcached-operator-system
+rules:
+- apiGroups: [""]
+  resources: ["configmaps"]
+  resourceNames: ["memcached-operator-lock"]
+  verbs: ["get", "update"]
+- apiGroups: ["coordination.k8s.io"]
+  resources: ["leases"]
+  verbs: ["get", "list", "watch", "create", "update", "patch", "delete"]
+...
+`
+
+func TestDecodeManifests(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "multi-document stream with empty documents",
+			stream: testManifestStream,
+			want: []string{
+				"Namespace/memcached-operator-system",
+				"ClusterRole/memcached-operator-manager-role",
+				"Role/memcached-operator-leader-election-role",
+			},
+		},
+		{
+			name:   "single document without separators",
+			stream: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+			want:   []string{"ConfigMap/config"},
+		},
+		{
+			name:   "only empty documents",
+			stream: "---\n---\n\n---\n",
+		},
+		{
+			name:   "empty stream",
+			stream: "",
+		},
+		{
+			name:    "invalid YAML",
+			stream:  "apiVersion: v1\nkind: [ConfigMap\n",
+			wantErr: true,
+		},
+		{
+			name:    "document without a kind",
+			stream:  "apiVersion: v1\nmetadata:\n  name: config\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifests, err := DecodeManifests([]byte(tt.stream))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d manifests", len(manifests))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, obj := range manifests {
+				got = append(got, obj.GetKind()+"/"+obj.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestsRBACRules(t *testing.T) {
+	manifests, err := DecodeManifests([]byte(testManifestStream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rules, err := manifests.RBACRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rules) != 2 {
+		t.Fatalf("got rules for %d roles, want 2", len(rules))
+	}
+	if got := rules["ClusterRole/memcached-operator-manager-role"]; len(got) != 2 {
+		t.Errorf("got %d ClusterRole rules, want 2", len(got))
+	}
+	if got := rules["Role/memcached-operator-leader-election-role"]; len(got) != 2 || !reflect.DeepEqual(got[0].ResourceNames, []string{"memcached-operator-lock"}) {
+		t.Errorf("got Role rules %+v, want the resourceNames of the first rule to be kept", got)
+	}
+}
+
+func TestRBACRuleAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     RBACRule
+		apiGroup string
+		resource string
+		verb     string
+		want     bool
+	}{
+		{
+			name:     "exact match",
+			rule:     RBACRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list"}},
+			apiGroup: "apps", resource: "deployments", verb: "list",
+			want: true,
+		},
+		{
+			name:     "verb not granted",
+			rule:     RBACRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list"}},
+			apiGroup: "apps", resource: "deployments", verb: "delete",
+		},
+		{
+			name:     "other API group",
+			rule:     RBACRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}},
+			apiGroup: "extensions", resource: "deployments", verb: "get",
+		},
+		{
+			name:     "core API group",
+			rule:     RBACRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+			apiGroup: "", resource: "pods", verb: "get",
+			want: true,
+		},
+		{
+			name:     "wildcard verbs",
+			rule:     RBACRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}},
+			apiGroup: "apps", resource: "deployments", verb: "patch",
+			want: true,
+		},
+		{
+			name:     "wildcard resources",
+			rule:     RBACRule{APIGroups: []string{"cache.example.com"}, Resources: []string{"*"}, Verbs: []string{"get"}},
+			apiGroup: "cache.example.com", resource: "memcacheds/status", verb: "get",
+			want: true,
+		},
+		{
+			name:     "wildcard API groups",
+			rule:     RBACRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			apiGroup: "coordination.k8s.io", resource: "leases", verb: "create",
+			want: true,
+		},
+		{
+			name:     "wildcard does not match other fields",
+			rule:     RBACRule{APIGroups: []string{"*"}, Resources: []string{"pods"}, Verbs: []string{"*"}},
+			apiGroup: "apps", resource: "deployments", verb: "get",
+		},
+		{
+			name:     "restricted to resource names",
+			rule:     RBACRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"lock"}, Verbs: []string{"*"}},
+			apiGroup: "", resource: "configmaps", verb: "get",
+		},
+		{
+			name:     "empty rule",
+			rule:     RBACRule{},
+			apiGroup: "", resource: "pods", verb: "get",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Allows(tt.apiGroup, tt.resource, tt.verb); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHaveRBACRule(t *testing.T) {
+	manifests, err := DecodeManifests([]byte(testManifestStream))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		apiGroup string
+		resource string
+		verbs    []string
+		want     bool
+	}{
+		{name: "verbs granted by one rule", apiGroup: "apps", resource: "deployments", verbs: []string{"get", "create"}, want: true},
+		{name: "verbs granted by a wildcard rule", apiGroup: "cache.example.com", resource: "memcacheds/finalizers", verbs: []string{"update"}, want: true},
+		{name: "verb not granted", apiGroup: "apps", resource: "deployments", verbs: []string{"get", "escalate"}},
+		{name: "rule restricted to resource names", apiGroup: "", resource: "configmaps", verbs: []string{"get"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HaveRBACRule(tt.apiGroup, tt.resource, tt.verbs...).Match(manifests)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package e2e
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// CreateResources creates the custom resources on the cluster. An error is returned
+// for the first resource that could not be created, i.e because it was invalid.
+func CreateResources(kubectl kubernetes.Kubectl, resources ...*samples.CustomResource) error {
+	for _, cr := range resources {
+		if _, err := applyResource(kubectl, cr); err != nil {
+			return fmt.Errorf("encountered an error when creating %s %q: %w", cr.Resource(), cr.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateResource applies the changes made to the custom resource and returns the
+// resulting metadata.generation, which can be passed to WaitForObservedGeneration
+func UpdateResource(kubectl kubernetes.Kubectl, cr *samples.CustomResource) (int64, error) {
+	if _, err := applyResource(kubectl, cr); err != nil {
+		return 0, fmt.Errorf("encountered an error when updating %s %q: %w", cr.Resource(), cr.Name(), err)
+	}
+
+	generation, err := resourceCommand(kubectl, cr, "get", cr.Resource(), cr.Name(), "-o", "jsonpath={.metadata.generation}")
+	if err != nil {
+		return 0, fmt.Errorf("encountered an error when getting the generation of %s %q: %w, output: %s", cr.Resource(), cr.Name(), err, generation)
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(generation), 10, 64)
+}
+
+// WaitForObservedGeneration waits until the status.observedGeneration of the
+// custom resource is at least the given generation
+func WaitForObservedGeneration(kubectl kubernetes.Kubectl, cr *samples.CustomResource, generation int64, timeout time.Duration) error {
+	var observed string
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		out, err := resourceCommand(kubectl, cr, "get", cr.Resource(), cr.Name(), "-o", "jsonpath={.status.observedGeneration}")
+		if err != nil {
+			return false, nil
+		}
+
+		observed = strings.TrimSpace(out)
+		observedGeneration, err := strconv.ParseInt(observed, 10, 64)
+		if err != nil {
+			return false, nil
+		}
+
+		return observedGeneration >= generation, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s %q observedGeneration is %q, expected at least %d: %w", cr.Resource(), cr.Name(), observed, generation, err)
+	}
+
+	return nil
+}
+
+// DeleteResources deletes the custom resources and waits for them to be removed,
+// including waiting for any finalizers to complete
+func DeleteResources(kubectl kubernetes.Kubectl, timeout time.Duration, resources ...*samples.CustomResource) error {
+	var errs []error
+	for _, cr := range resources {
+		out, err := resourceCommand(kubectl, cr, "delete", cr.Resource(), cr.Name(),
+			"--ignore-not-found", "--wait", "--timeout", timeout.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("encountered an error when deleting %s %q: %w, output: %s", cr.Resource(), cr.Name(), err, out))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func applyResource(kubectl kubernetes.Kubectl, cr *samples.CustomResource, options ...string) (string, error) {
+	manifest, err := cr.YAML()
+	if err != nil {
+		return "", err
+	}
+
+	var out string
+	if cr.Namespace() == "" {
+		out, err = kubernetes.ApplyManifest(kubectl, true, manifest, options...)
+	} else {
+		out, err = kubernetes.ApplyManifest(kubectl, false, manifest, options...)
+	}
+	if err != nil {
+		return out, fmt.Errorf("%w, output: %s", err, out)
+	}
+
+	return out, nil
+}
+
+// resourceCommand runs a kubectl command in the namespace of the custom
+// resource, falling back to the Kubectl namespace if it does not have one
+func resourceCommand(kubectl kubernetes.Kubectl, cr *samples.CustomResource, options ...string) (string, error) {
+	if cr.Namespace() == "" {
+		return kubectl.CommandInNamespace(options...)
+	}
+
+	return kubectl.Command(append([]string{"-n", cr.Namespace()}, options...)...)
+}
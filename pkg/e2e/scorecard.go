@@ -0,0 +1,238 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// ScorecardState is the outcome of a scorecard test
+type ScorecardState string
+
+const (
+	ScorecardPass  ScorecardState = "pass"
+	ScorecardFail  ScorecardState = "fail"
+	ScorecardError ScorecardState = "error"
+)
+
+// ScorecardResult is the result of a single scorecard test
+type ScorecardResult struct {
+	Name        string
+	State       ScorecardState
+	Log         string
+	Errors      []string
+	Suggestions []string
+	// Labels are the labels of the test the result belongs to, i.e suite=basic
+	Labels map[string]string
+}
+
+// ScorecardResults are the results of a scorecard run
+type ScorecardResults []ScorecardResult
+
+// WithState returns the results that are in the given state
+func (sr ScorecardResults) WithState(state ScorecardState) ScorecardResults {
+	var results ScorecardResults
+	for _, result := range sr {
+		if result.State == state {
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// Get returns the result of the test with the given name
+func (sr ScorecardResults) Get(name string) (ScorecardResult, bool) {
+	for _, result := range sr {
+		if result.Name == name {
+			return result, true
+		}
+	}
+
+	return ScorecardResult{}, false
+}
+
+// scorecardTestList is the `operator-sdk scorecard -o json` output
+type scorecardTestList struct {
+	Items []struct {
+		Spec struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"spec"`
+		Status struct {
+			Results []struct {
+				Name        string   `json:"name"`
+				Log         string   `json:"log"`
+				State       string   `json:"state"`
+				Errors      []string `json:"errors"`
+				Suggestions []string `json:"suggestions"`
+			} `json:"results"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// RunScorecard runs `operator-sdk scorecard` against the bundle of the sample in the
+// Kubectl namespace and returns the parsed results. The selector (i.e suite=basic) is
+// passed as the label selector when not empty. Failing tests are not returned as an
+// error, use the results or the PassScorecard matcher to check them.
+func RunScorecard(sample samples.Sample, kubectl kubernetes.Kubectl, selector string, opts ...OLMOption) (ScorecardResults, error) {
+	oo := newOLMOptions(opts...)
+
+	args := []string{"scorecard", "./bundle",
+		"--namespace", kubectl.Namespace(),
+		"--wait-time", oo.timeout.String(),
+		"--output", "json",
+	}
+	if selector != "" {
+		args = append(args, "--selector", selector)
+	}
+	if kubeconfig := kubernetes.KubeconfigOf(kubectl); kubeconfig != "" {
+		args = append(args, "--kubeconfig", kubeconfig)
+	}
+	args = append(args, oo.extraArgs...)
+
+	cmd := exec.Command(oo.binary, args...)
+	// scorecard exits with an error when any test fails, so only
+	// report an error if the output could not be parsed as results
+	out, runErr := sample.CommandContext().Run(cmd, sample.Name())
+
+	results, err := ParseScorecardResults(out)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when running scorecard: %v, output: %s", runErr, string(out))
+	}
+
+	return results, nil
+}
+
+// ParseScorecardResults parses the output of `operator-sdk scorecard -o json`
+func ParseScorecardResults(out []byte) (ScorecardResults, error) {
+	start := jsonDocumentStart(out)
+	if start < 0 {
+		return nil, fmt.Errorf("no scorecard results found")
+	}
+
+	var list scorecardTestList
+	if err := json.NewDecoder(bytes.NewReader(out[start:])).Decode(&list); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding scorecard results: %w", err)
+	}
+
+	var results ScorecardResults
+	for _, item := range list.Items {
+		for _, r := range item.Status.Results {
+			results = append(results, ScorecardResult{
+				Name:        r.Name,
+				State:       ScorecardState(r.State),
+				Log:         r.Log,
+				Errors:      r.Errors,
+				Suggestions: r.Suggestions,
+				Labels:      item.Spec.Labels,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// jsonDocumentStart returns the index of the first line of out that starts a JSON object,
+// skipping anything written before the document (i.e warnings and log lines), or -1
+func jsonDocumentStart(out []byte) int {
+	if bytes.HasPrefix(out, []byte("{")) {
+		return 0
+	}
+
+	start := bytes.Index(out, []byte("\n{"))
+	if start < 0 {
+		return -1
+	}
+
+	return start + 1
+}
+
+// PassScorecard succeeds if the actual ScorecardResults are not empty and every test passed
+func PassScorecard() types.GomegaMatcher {
+	return &passScorecardMatcher{}
+}
+
+type passScorecardMatcher struct{}
+
+func (psm *passScorecardMatcher) Match(actual interface{}) (bool, error) {
+	results, ok := actual.(ScorecardResults)
+	if !ok {
+		return false, fmt.Errorf("PassScorecard matcher expects ScorecardResults. Got:\n%s", format.Object(actual, 1))
+	}
+
+	return len(results) > 0 && len(results) == len(results.WithState(ScorecardPass)), nil
+}
+
+func (psm *passScorecardMatcher) FailureMessage(actual interface{}) string {
+	results, _ := actual.(ScorecardResults)
+	if len(results) == 0 {
+		return "Expected scorecard tests to pass, but no tests were run"
+	}
+
+	var b strings.Builder
+	b.WriteString("Expected all scorecard tests to pass, but:\n")
+	for _, result := range results {
+		if result.State == ScorecardPass {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s: %s\n", result.Name, result.State)
+		for _, e := range result.Errors {
+			fmt.Fprintf(&b, "\t\terror: %s\n", e)
+		}
+		for _, s := range result.Suggestions {
+			fmt.Fprintf(&b, "\t\tsuggestion: %s\n", s)
+		}
+	}
+
+	return b.String()
+}
+
+func (psm *passScorecardMatcher) NegatedFailureMessage(actual interface{}) string {
+	return "Expected some scorecard tests not to pass, but all of them passed"
+}
+
+// HaveScorecardResult succeeds if the actual ScorecardResults contain
+// a result for the named test that is in the given state
+func HaveScorecardResult(name string, state ScorecardState) types.GomegaMatcher {
+	return &haveScorecardResultMatcher{
+		name:  name,
+		state: state,
+	}
+}
+
+type haveScorecardResultMatcher struct {
+	name  string
+	state ScorecardState
+}
+
+func (hsrm *haveScorecardResultMatcher) Match(actual interface{}) (bool, error) {
+	results, ok := actual.(ScorecardResults)
+	if !ok {
+		return false, fmt.Errorf("HaveScorecardResult matcher expects ScorecardResults. Got:\n%s", format.Object(actual, 1))
+	}
+
+	result, found := results.Get(hsrm.name)
+	return found && result.State == hsrm.state, nil
+}
+
+func (hsrm *haveScorecardResultMatcher) FailureMessage(actual interface{}) string {
+	results, _ := actual.(ScorecardResults)
+	result, found := results.Get(hsrm.name)
+	if !found {
+		return fmt.Sprintf("Expected scorecard results to contain test %q, but it was not run", hsrm.name)
+	}
+
+	return fmt.Sprintf("Expected scorecard test %q to be %s, but it was %s\nerrors: %v\nlog:\n%s",
+		hsrm.name, hsrm.state, result.State, result.Errors, result.Log)
+}
+
+func (hsrm *haveScorecardResultMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected scorecard test %q not to be %s", hsrm.name, hsrm.state)
+}
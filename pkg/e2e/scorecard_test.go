@@ -0,0 +1,129 @@
+package e2e
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testScorecardOutput = `{
+  "apiVersion": "scorecard.operatorframework.io/v1alpha3",
+  "kind": "TestList",
+  "items": [
+    {
+      "kind": "Test",
+      "spec": {"image": "quay.io/operator-framework/scorecard-test", "labels": {"suite": "basic", "test": "basic-check-spec-test"}},
+      "status": {"results": [{"name": "basic-check-spec", "state": "pass"}]}
+    },
+    {
+      "kind": "Test",
+      "spec": {"image": "quay.io/operator-framework/scorecard-test", "labels": {"suite": "olm", "test": "olm-crds-have-validation-test"}},
+      "status": {"results": [{
+        "name": "olm-crds-have-validation",
+        "state": "fail",
+        "log": "Loaded 1 Custom Resources from alm-examples",
+        "errors": ["Memcached does not have spec validation"],
+        "suggestions": ["Add a validation block to the CRD"]
+      }]}
+    }
+  ]
+}
+`
+
+func TestParseScorecardResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    ScorecardResults
+		wantErr bool
+	}{
+		{
+			name: "empty test list",
+			out:  `{"kind": "TestList", "items": []}`,
+		},
+		{
+			name: "results of every test",
+			out:  testScorecardOutput,
+			want: testScorecardResults,
+		},
+		{
+			name: "leading stderr noise",
+			out: "time=\"2022-06-01T12:00:00Z\" level=warning msg=\"scorecard config {not json}\"\n" +
+				"W0601 12:00:00.000000 client_config.go:617] Neither --kubeconfig nor --master was specified\n" +
+				testScorecardOutput,
+			want: testScorecardResults,
+		},
+		{
+			name: "leading stderr noise without braces",
+			out:  "W0601 12:00:00.000000 client_config.go:617] Neither --kubeconfig nor --master was specified\n" + testScorecardOutput,
+			want: testScorecardResults,
+		},
+		{
+			name: "trailing output after the results",
+			out:  testScorecardOutput + "Error: some tests failed\n",
+			want: testScorecardResults,
+		},
+		{
+			name:    "results not at the start of a line",
+			out:     "warning: " + testScorecardOutput,
+			wantErr: true,
+		},
+		{
+			name:    "no results",
+			out:     "Error: no bundle found\n",
+			wantErr: true,
+		},
+		{
+			name:    "truncated results",
+			out:     testScorecardOutput[:len(testScorecardOutput)/2],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScorecardResults([]byte(tt.out))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScorecardResultsWithState(t *testing.T) {
+	failed := testScorecardResults.WithState(ScorecardFail)
+	if len(failed) != 1 || failed[0].Name != "olm-crds-have-validation" {
+		t.Errorf("got %+v, want only olm-crds-have-validation", failed)
+	}
+
+	if _, ok := testScorecardResults.Get("basic-check-spec"); !ok {
+		t.Errorf("expected basic-check-spec to be found")
+	}
+	if _, ok := testScorecardResults.Get("missing"); ok {
+		t.Errorf("expected missing not to be found")
+	}
+}
+
+var testScorecardResults = ScorecardResults{
+	{
+		Name:   "basic-check-spec",
+		State:  ScorecardPass,
+		Labels: map[string]string{"suite": "basic", "test": "basic-check-spec-test"},
+	},
+	{
+		Name:        "olm-crds-have-validation",
+		State:       ScorecardFail,
+		Log:         "Loaded 1 Custom Resources from alm-examples",
+		Errors:      []string{"Memcached does not have spec validation"},
+		Suggestions: []string{"Add a validation block to the CRD"},
+		Labels:      map[string]string{"suite": "olm", "test": "olm-crds-have-validation-test"},
+	},
+}
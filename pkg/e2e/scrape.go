@@ -0,0 +1,94 @@
+package e2e
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// metricsServicePort is the port the scaffolded metrics service serves on
+const metricsServicePort = 8443
+
+// GrantMetricsAccess allows the Kubectl ServiceAccount to read the operator metrics
+// by binding it to the metrics-reader ClusterRole scaffolded for the sample
+func GrantMetricsAccess(sample samples.Sample, kubectl kubernetes.Kubectl, clusterRoleBindingName string) error {
+	out, err := kubectl.Command("create", "clusterrolebinding", clusterRoleBindingName,
+		fmt.Sprintf("--clusterrole=%s-metrics-reader", sample.Name()),
+		fmt.Sprintf("--serviceaccount=%s:%s", kubectl.Namespace(), kubectl.ServiceAccount()))
+	if err != nil {
+		return fmt.Errorf("encountered an error when creating the metrics clusterrolebinding: %w, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// ScrapeMetrics scrapes the metrics endpoint of the operator through a port-forward to
+// the metrics service instead of a curl pod, authenticating with a token for the Kubectl
+// ServiceAccount. The ServiceAccount must be allowed to read the metrics, see GrantMetricsAccess.
+func ScrapeMetrics(sample samples.Sample, kubectl kubernetes.Kubectl) (Metrics, error) {
+	token, cleanupToken, err := kubernetes.ServiceAccountToken(kubectl, kubectl.ServiceAccount(), "", 10*time.Minute)
+	if cleanupToken != nil {
+		defer cleanupToken()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when getting a token for the service account: %w", err)
+	}
+
+	service := fmt.Sprintf("service/%s-controller-manager-metrics-service", sample.Name())
+	pf, err := kubernetes.PortForward(kubectl, service, metricsServicePort)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when port-forwarding to the metrics service: %w", err)
+	}
+	defer pf.Stop()
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// The metrics endpoint serves with a self-signed certificate
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	var body string
+	var lastErr error
+	err = wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		body, lastErr = getMetricsBody(client, fmt.Sprintf("https://%s/metrics", pf.Address()), token)
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when scraping the metrics endpoint: %v", lastErr)
+	}
+
+	return ParseMetrics(body)
+}
+
+func getMetricsBody(client *http.Client, url string, token string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metrics endpoint returned status %q: %s", resp.Status, string(b))
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,398 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbutil "sigs.k8s.io/kubebuilder/v3/pkg/plugin/util"
+)
+
+// UpgradeOptions configures how an UpgradeScenario moves from one version of a sample to another
+type UpgradeOptions struct {
+	resources     []*samples.CustomResource
+	reconcileOpts []ReconcileOption
+	controlPlane  string
+	timeout       time.Duration
+	beforeUpgrade func() error
+}
+
+type UpgradeOption func(uo *UpgradeOptions)
+
+// WithUpgradeResources sets the custom resources that are created before the upgrade
+// and expected to survive it. Defaults to the scaffolded sample of the old version.
+func WithUpgradeResources(resources ...*samples.CustomResource) UpgradeOption {
+	return func(uo *UpgradeOptions) {
+		uo.resources = append(uo.resources, resources...)
+	}
+}
+
+// WithUpgradeReconcileOptions sets the expectations that are checked, with ExpectReconciled,
+// for each custom resource before and after the upgrade
+func WithUpgradeReconcileOptions(opts ...ReconcileOption) UpgradeOption {
+	return func(uo *UpgradeOptions) {
+		uo.reconcileOpts = append(uo.reconcileOpts, opts...)
+	}
+}
+
+// WithUpgradeControlPlane sets the control-plane label of the controller pods.
+// Defaults to "controller-manager".
+func WithUpgradeControlPlane(controlPlane string) UpgradeOption {
+	return func(uo *UpgradeOptions) {
+		uo.controlPlane = controlPlane
+	}
+}
+
+// WithUpgradeTimeout sets how long to wait for each step of the upgrade
+func WithUpgradeTimeout(timeout time.Duration) UpgradeOption {
+	return func(uo *UpgradeOptions) {
+		uo.timeout = timeout
+	}
+}
+
+// WithBeforeUpgrade sets a function that is run after the custom resources of the
+// old version have been reconciled and before the new version is deployed
+func WithBeforeUpgrade(beforeUpgrade func() error) UpgradeOption {
+	return func(uo *UpgradeOptions) {
+		uo.beforeUpgrade = beforeUpgrade
+	}
+}
+
+// UpgradeScenario deploys one version of an operator and upgrades it in place to another.
+// Both samples are expected to be the same project, i.e scaffolded by an older and newer
+// plugin binary, so they share a name and the resources they deploy.
+type UpgradeScenario struct {
+	from      samples.Sample
+	fromImage string
+	to        samples.Sample
+	toImage   string
+	opts      *UpgradeOptions
+}
+
+// NewUpgradeScenario creates an UpgradeScenario from the old sample and image to the new sample and image
+func NewUpgradeScenario(from samples.Sample, fromImage string, to samples.Sample, toImage string, opts ...UpgradeOption) *UpgradeScenario {
+	uo := &UpgradeOptions{
+		controlPlane: "controller-manager",
+		timeout:      5 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(uo)
+	}
+
+	return &UpgradeScenario{
+		from:      from,
+		fromImage: fromImage,
+		to:        to,
+		toImage:   toImage,
+		opts:      uo,
+	}
+}
+
+// upgradeState is what is recorded about the cluster before the upgrade
+type upgradeState struct {
+	// uids of the custom resources keyed by name
+	uids map[string]string
+	// storedVersions of the CRD of each custom resource, keyed by resource
+	storedVersions map[string][]string
+}
+
+// Run deploys the old version, creates the custom resources and waits for them to be
+// reconciled, then deploys the new version. After the upgrade it checks that the
+// custom resources still exist and were not recreated, that the controller pods were
+// rolled out and are running without restarts, that every CRD version that was stored
+// before the upgrade can still be read and that the custom resources are reconciled
+// by the new version. Every check is run and all failures are returned together.
+func (us *UpgradeScenario) Run(kubectl kubernetes.Kubectl) error {
+	resources := us.opts.resources
+	if len(resources) == 0 {
+		cr, err := samples.LoadCustomResource(us.from)
+		if err != nil {
+			return err
+		}
+		resources = []*samples.CustomResource{cr}
+	}
+
+	if err := DeployOperator(us.from, us.fromImage); err != nil {
+		return fmt.Errorf("encountered an error when deploying the version to upgrade from: %w", err)
+	}
+
+	if err := us.waitForControllers(kubectl); err != nil {
+		return fmt.Errorf("version to upgrade from is not running: %w", err)
+	}
+
+	if err := CreateResources(kubectl, resources...); err != nil {
+		return err
+	}
+
+	if err := us.expectReconciled(us.from, kubectl, resources); err != nil {
+		return fmt.Errorf("custom resources were not reconciled before the upgrade: %w", err)
+	}
+
+	before, err := recordUpgradeState(kubectl, resources)
+	if err != nil {
+		return err
+	}
+
+	oldPods, err := us.controllerPods(kubectl)
+	if err != nil {
+		return err
+	}
+
+	if us.opts.beforeUpgrade != nil {
+		if err := us.opts.beforeUpgrade(); err != nil {
+			return fmt.Errorf("encountered an error when running the before upgrade hook: %w", err)
+		}
+	}
+
+	if err := DeployOperator(us.to, us.toImage); err != nil {
+		return fmt.Errorf("encountered an error when deploying the version to upgrade to: %w", err)
+	}
+
+	var problems []string
+
+	if err := us.waitForRollout(kubectl, oldPods); err != nil {
+		problems = append(problems, err.Error())
+	} else if err := us.checkRestarts(kubectl); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	problems = append(problems, checkResourcesSurvived(kubectl, resources, before)...)
+	problems = append(problems, checkStoredVersionsReadable(kubectl, resources, before)...)
+
+	if err := us.expectReconciled(us.to, kubectl, resources); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("upgrade of %s from %s to %s failed:\n\t%s",
+			us.to.Name(), us.fromImage, us.toImage, strings.Join(problems, "\n\t"))
+	}
+
+	return nil
+}
+
+func (us *UpgradeScenario) expectReconciled(sample samples.Sample, kubectl kubernetes.Kubectl, resources []*samples.CustomResource) error {
+	if len(us.opts.reconcileOpts) == 0 {
+		return nil
+	}
+
+	for _, cr := range resources {
+		opts := append([]ReconcileOption{WithReconcileTimeout(us.opts.timeout)}, us.opts.reconcileOpts...)
+		opts = append(opts, WithResourceName(cr.Name()))
+		if err := ExpectReconciled(sample, kubectl, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (us *UpgradeScenario) waitForControllers(kubectl kubernetes.Kubectl) error {
+	var lastErr error
+	err := wait.PollImmediate(time.Second, us.opts.timeout, func() (bool, error) {
+		lastErr = EnsureOperatorRunning(kubectl, 1, "controller-manager", us.opts.controlPlane)
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("controller pods were not running within %s: %v", us.opts.timeout, lastErr)
+	}
+
+	return nil
+}
+
+// controllerPods returns the names of the controller pods that are not being deleted
+func (us *UpgradeScenario) controllerPods(kubectl kubernetes.Kubectl) ([]string, error) {
+	out, err := kubectl.Get(true, "pods", "-l", "control-plane="+us.opts.controlPlane,
+		"-o", "go-template={{ range .items }}{{ if not .metadata.deletionTimestamp }}{{ .metadata.name }}"+
+			"{{ \"\\n\" }}{{ end }}{{ end }}")
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when getting the controller pods: %w, output: %s", err, out)
+	}
+
+	return kbutil.GetNonEmptyLines(out), nil
+}
+
+// waitForRollout waits for the controller deployment to finish rolling out. If the
+// images of the versions differ the old pods are also expected to be replaced.
+func (us *UpgradeScenario) waitForRollout(kubectl kubernetes.Kubectl, oldPods []string) error {
+	deployment := "deployment/" + us.to.Name() + "-controller-manager"
+	out, err := kubectl.CommandInNamespace("rollout", "status", deployment, "--timeout", us.opts.timeout.String())
+	if err != nil {
+		return fmt.Errorf("%s was not rolled out: %v, output: %s", deployment, err, out)
+	}
+
+	if us.fromImage == us.toImage {
+		return nil
+	}
+
+	var pods []string
+	err = wait.PollImmediate(time.Second, us.opts.timeout, func() (bool, error) {
+		pods, err = us.controllerPods(kubectl)
+		if err != nil {
+			return false, nil
+		}
+		for _, pod := range pods {
+			for _, oldPod := range oldPods {
+				if pod == oldPod {
+					return false, nil
+				}
+			}
+		}
+		return len(pods) > 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("controller pods %v were not replaced, current pods: %v", oldPods, pods)
+	}
+
+	return nil
+}
+
+// checkRestarts returns an error if any container of the controller pods has restarted
+// or is not running, which usually means the new version is crash looping
+func (us *UpgradeScenario) checkRestarts(kubectl kubernetes.Kubectl) error {
+	if err := us.waitForControllers(kubectl); err != nil {
+		return err
+	}
+
+	out, err := kubectl.Get(true, "pods", "-l", "control-plane="+us.opts.controlPlane,
+		"-o", `jsonpath={range .items[*]}{range .status.containerStatuses[*]}{.name} {.restartCount}{"\n"}{end}{end}`)
+	if err != nil {
+		return fmt.Errorf("encountered an error when getting the controller container statuses: %v, output: %s", err, out)
+	}
+
+	var restarted []string
+	for _, line := range kbutil.GetNonEmptyLines(out) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] != "0" {
+			restarted = append(restarted, fmt.Sprintf("%s restarted %s times", fields[0], fields[1]))
+		}
+	}
+
+	if len(restarted) > 0 {
+		return fmt.Errorf("controller containers restarted after the upgrade: %s", strings.Join(restarted, ", "))
+	}
+
+	return nil
+}
+
+func recordUpgradeState(kubectl kubernetes.Kubectl, resources []*samples.CustomResource) (*upgradeState, error) {
+	state := &upgradeState{
+		uids:           make(map[string]string),
+		storedVersions: make(map[string][]string),
+	}
+
+	for _, cr := range resources {
+		uid, err := resourceCommand(kubectl, cr, "get", cr.Resource(), cr.Name(), "-o", "jsonpath={.metadata.uid}")
+		if err != nil {
+			return nil, fmt.Errorf("encountered an error when getting %s %q: %w, output: %s", cr.Resource(), cr.Name(), err, uid)
+		}
+		state.uids[cr.Resource()+"/"+cr.Name()] = strings.TrimSpace(uid)
+
+		if _, ok := state.storedVersions[cr.Resource()]; ok {
+			continue
+		}
+
+		versions, err := crdStoredVersions(kubectl, cr)
+		if err != nil {
+			return nil, err
+		}
+		state.storedVersions[cr.Resource()] = versions
+	}
+
+	return state, nil
+}
+
+// crdList is the part of `kubectl get crd -o json` needed to find the stored versions of a CRD
+type crdList struct {
+	Items []struct {
+		Spec struct {
+			Group string `json:"group"`
+			Names struct {
+				Kind   string `json:"kind"`
+				Plural string `json:"plural"`
+			} `json:"names"`
+		} `json:"spec"`
+		Status struct {
+			StoredVersions []string `json:"storedVersions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// crdStoredVersions returns the status.storedVersions of the CRD that defines the
+// custom resource as fully qualified resources, i.e memcacheds.v1alpha1.cache.example.com
+func crdStoredVersions(kubectl kubernetes.Kubectl, cr *samples.CustomResource) ([]string, error) {
+	gvk := cr.Object().GroupVersionKind()
+
+	out, err := kubectl.Get(false, "crd", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when getting the CRDs: %w, output: %s", err, out)
+	}
+
+	start := jsonDocumentStart([]byte(out))
+	if start < 0 {
+		return nil, fmt.Errorf("no CRDs found in the output: %s", out)
+	}
+
+	var crds crdList
+	if err := json.NewDecoder(strings.NewReader(out[start:])).Decode(&crds); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding the CRDs: %w", err)
+	}
+
+	for _, crd := range crds.Items {
+		if crd.Spec.Group != gvk.Group || crd.Spec.Names.Kind != gvk.Kind {
+			continue
+		}
+
+		var versions []string
+		for _, version := range crd.Status.StoredVersions {
+			versions = append(versions, crd.Spec.Names.Plural+"."+version+"."+gvk.Group)
+		}
+		return versions, nil
+	}
+
+	return nil, fmt.Errorf("no CRD found for %s", gvk.GroupKind())
+}
+
+func checkResourcesSurvived(kubectl kubernetes.Kubectl, resources []*samples.CustomResource, before *upgradeState) []string {
+	var problems []string
+	for _, cr := range resources {
+		out, err := resourceCommand(kubectl, cr, "get", cr.Resource(), cr.Name(), "--ignore-not-found", "-o", "jsonpath={.metadata.uid}")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("could not get %s %q: %v: %s", cr.Resource(), cr.Name(), err, out))
+			continue
+		}
+
+		uid := strings.TrimSpace(out)
+		switch uid {
+		case "":
+			problems = append(problems, fmt.Sprintf("%s %q no longer exists", cr.Resource(), cr.Name()))
+		case before.uids[cr.Resource()+"/"+cr.Name()]:
+		default:
+			problems = append(problems, fmt.Sprintf("%s %q was recreated", cr.Resource(), cr.Name()))
+		}
+	}
+
+	return problems
+}
+
+// checkStoredVersionsReadable reads every custom resource through each version
+// that was stored before the upgrade to make sure it is still served
+func checkStoredVersionsReadable(kubectl kubernetes.Kubectl, resources []*samples.CustomResource, before *upgradeState) []string {
+	var problems []string
+	for _, cr := range resources {
+		for _, version := range before.storedVersions[cr.Resource()] {
+			out, err := resourceCommand(kubectl, cr, "get", version, cr.Name(), "-o", "name")
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("could not read %s %q as stored version %s: %v: %s",
+					cr.Resource(), cr.Name(), version, err, strings.TrimSpace(out)))
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,124 @@
+package e2e
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/kubernetes"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// webhookUnavailableMessages are returned by the API server when it could not reach a webhook
+var webhookUnavailableMessages = []string{
+	"failed calling webhook",
+	"connection refused",
+	"no endpoints available",
+	"context deadline exceeded",
+}
+
+// ExpectAdmissionDenied submits the custom resource to the API server as a server side
+// dry run and returns an error unless it was denied by an admission webhook with a
+// message containing messageSubstring. Nothing is persisted to the cluster.
+func ExpectAdmissionDenied(kubectl kubernetes.Kubectl, obj *samples.CustomResource, messageSubstring string) error {
+	out, err := applyResource(kubectl, obj, "--dry-run=server")
+	if err == nil {
+		return fmt.Errorf("expected %s %q to be denied admission, but it was admitted", obj.Resource(), obj.Name())
+	}
+
+	if !strings.Contains(out, "denied the request") {
+		return fmt.Errorf("expected %s %q to be denied by an admission webhook, but got: %w", obj.Resource(), obj.Name(), err)
+	}
+
+	if !strings.Contains(out, messageSubstring) {
+		return fmt.Errorf("expected %s %q to be denied with a message containing %q, but got: %s", obj.Resource(), obj.Name(), messageSubstring, out)
+	}
+
+	return nil
+}
+
+// ExpectDefaulted submits the custom resource to the API server as a server side dry run
+// and returns an error unless the admitted object has the value at the dot separated
+// fieldPath (i.e spec.size). Nothing is persisted to the cluster.
+func ExpectDefaulted(kubectl kubernetes.Kubectl, obj *samples.CustomResource, fieldPath string, value interface{}) error {
+	out, err := applyResource(kubectl, obj, "--dry-run=server", "-o", "json")
+	if err != nil {
+		return fmt.Errorf("expected %s %q to be admitted: %w", obj.Resource(), obj.Name(), err)
+	}
+
+	// Skip anything written before the JSON document, i.e warnings
+	start := jsonDocumentStart([]byte(out))
+	if start < 0 {
+		return fmt.Errorf("no admitted object found in the output: %s", out)
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(out[start:])).Decode(&raw); err != nil {
+		return fmt.Errorf("encountered an error when decoding the admitted object: %w", err)
+	}
+
+	admitted := &unstructured.Unstructured{}
+	if err := admitted.UnmarshalJSON(raw); err != nil {
+		return fmt.Errorf("encountered an error when decoding the admitted object: %w", err)
+	}
+
+	actual, found, err := unstructured.NestedFieldNoCopy(admitted.Object, strings.Split(fieldPath, ".")...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when reading %s from the admitted object: %w", fieldPath, err)
+	}
+	if !found {
+		return fmt.Errorf("expected %s of %s %q to be defaulted to %v, but it is not set", fieldPath, obj.Resource(), obj.Name(), value)
+	}
+
+	expectedJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encountered an error when encoding the expected value: %w", err)
+	}
+	actualJSON, err := json.Marshal(actual)
+	if err != nil {
+		return fmt.Errorf("encountered an error when encoding the actual value: %w", err)
+	}
+
+	if string(expectedJSON) != string(actualJSON) {
+		return fmt.Errorf("expected %s of %s %q to be defaulted to %s, but it is %s", fieldPath, obj.Resource(), obj.Name(), expectedJSON, actualJSON)
+	}
+
+	return nil
+}
+
+// WaitForWebhookReady polls until the webhooks of the sample answer admission requests. It
+// submits the scaffolded custom resource sample as a server side dry run until it is admitted
+// or denied by a webhook, polling while the API server reports that it could not reach the
+// webhook. Any other error is returned immediately.
+func WaitForWebhookReady(sample samples.Sample, kubectl kubernetes.Kubectl, timeout time.Duration) error {
+	cr, err := samples.LoadCustomResource(sample)
+	if err != nil {
+		return err
+	}
+
+	var out string
+	err = wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		var applyErr error
+		out, applyErr = applyResource(kubectl, cr, "--dry-run=server")
+		if applyErr == nil || strings.Contains(out, "denied the request") {
+			return true, nil
+		}
+
+		for _, msg := range webhookUnavailableMessages {
+			if strings.Contains(out, msg) {
+				return false, nil
+			}
+		}
+
+		return false, fmt.Errorf("encountered an error when submitting %s %q to the webhooks of %s: %w", cr.Resource(), cr.Name(), sample.Name(), applyErr)
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return fmt.Errorf("webhooks for %s were not ready within %s, last output: %s", sample.Name(), timeout, out)
+	}
+
+	return err
+}
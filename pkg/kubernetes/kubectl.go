@@ -1,8 +1,10 @@
 package kubernetes
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 
 	"github.com/everettraven/plugin-testing-poc/pkg/command"
@@ -24,11 +26,50 @@ type Kubectl interface {
 	Version() (KubernetesVersion, error)
 }
 
+// KubeconfigProvider is optionally implemented by a Kubectl that connects to the cluster
+// with a specific kubeconfig file, so other tools run against the same cluster can use it
+type KubeconfigProvider interface {
+	Kubeconfig() string
+}
+
+// KubeconfigOf returns the kubeconfig file of the Kubectl, or an empty
+// string if it does not implement KubeconfigProvider or uses the default
+func KubeconfigOf(kubectl Kubectl) string {
+	if kp, ok := kubectl.(KubeconfigProvider); ok {
+		return kp.Kubeconfig()
+	}
+	return ""
+}
+
+// OutputInNamespace runs kubectl in the Kubectl namespace and returns only what it wrote to
+// stdout, so warnings written to stderr do not end up in output that is parsed
+func OutputInNamespace(kubectl Kubectl, options ...string) (string, error) {
+	options = append([]string{"-n", kubectl.Namespace()}, options...)
+	if kubeconfig := KubeconfigOf(kubectl); kubeconfig != "" {
+		options = append([]string{"--kubeconfig", kubeconfig}, options...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("kubectl", options...)
+	cmd.Dir = kubectl.CommandContext().Dir()
+	cmd.Env = append(os.Environ(), kubectl.CommandContext().Env()...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	fmt.Println("Running command:", cmd.String())
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w, output: %s", err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
+
 // TODO: Add a default here
 type KubectlUtil struct {
 	commandContext command.CommandContext
 	namespace      string
 	serviceAccount string
+	kubeconfig     string
 }
 
 type KubectlUtilOptions func(ku *KubectlUtil)
@@ -51,6 +92,14 @@ func WithServiceAccount(sa string) KubectlUtilOptions {
 	}
 }
 
+// WithKubeconfig sets the kubeconfig file that is used to connect to the cluster.
+// When not set kubectl falls back to its default loading rules.
+func WithKubeconfig(kubeconfig string) KubectlUtilOptions {
+	return func(ku *KubectlUtil) {
+		ku.kubeconfig = kubeconfig
+	}
+}
+
 // TODO: Implement interface
 
 func NewKubectlUtil(opts ...KubectlUtilOptions) *KubectlUtil {
@@ -79,7 +128,14 @@ func (ku *KubectlUtil) ServiceAccount() string {
 	return ku.serviceAccount
 }
 
+func (ku *KubectlUtil) Kubeconfig() string {
+	return ku.kubeconfig
+}
+
 func (ku *KubectlUtil) Command(options ...string) (string, error) {
+	if ku.kubeconfig != "" {
+		options = append([]string{"--kubeconfig", ku.kubeconfig}, options...)
+	}
 	cmd := exec.Command("kubectl", options...)
 	output, err := ku.commandContext.Run(cmd)
 	return string(output), err
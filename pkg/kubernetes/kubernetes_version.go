@@ -2,6 +2,8 @@ package kubernetes
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -97,3 +99,29 @@ func (kv *KubeVersion) ClientVersion() VersionInfo {
 func (kv *KubeVersion) ServerVersion() VersionInfo {
 	return &kv.serverVersion
 }
+
+// ServerVersionAtLeast returns whether the Kubernetes server version is
+// greater than or equal to the provided major and minor version
+func ServerVersionAtLeast(kubectl Kubectl, major uint64, minor uint64) (bool, error) {
+	kubeVersion, err := kubectl.Version()
+	if err != nil {
+		return false, fmt.Errorf("encountered an error trying to get Kubernetes Version: %w", err)
+	}
+
+	serverMajor, err := strconv.ParseUint(kubeVersion.ServerVersion().Major(), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("encountered an error trying to parse Kubernetes Major Version: %w", err)
+	}
+
+	// Some providers suffix the minor version with a "+" (i.e "24+")
+	serverMinor, err := strconv.ParseUint(strings.TrimSuffix(kubeVersion.ServerVersion().Minor(), "+"), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("encountered an error trying to parse Kubernetes Minor Version: %w", err)
+	}
+
+	if serverMajor != major {
+		return serverMajor > major, nil
+	}
+
+	return serverMinor >= minor, nil
+}
@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ApplyManifest writes the manifest to a temporary file and applies it with `kubectl apply`
+func ApplyManifest(kubectl Kubectl, inNamespace bool, manifest []byte, options ...string) (string, error) {
+	return withManifestFile(manifest, func(path string) (string, error) {
+		return kubectl.Apply(inNamespace, append([]string{"-f", path}, options...)...)
+	})
+}
+
+// CreateManifest writes the manifest to a temporary file and creates it with `kubectl create`,
+// which unlike ApplyManifest fails if the objects already exist
+func CreateManifest(kubectl Kubectl, inNamespace bool, manifest []byte, options ...string) (string, error) {
+	return withManifestFile(manifest, func(path string) (string, error) {
+		options = append([]string{"create", "-f", path}, options...)
+		if inNamespace {
+			return kubectl.CommandInNamespace(options...)
+		}
+		return kubectl.Command(options...)
+	})
+}
+
+func withManifestFile(manifest []byte, run func(path string) (string, error)) (string, error) {
+	dir, err := os.MkdirTemp("", "manifest-")
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when creating a temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "manifest.yaml")
+	err = os.WriteFile(path, manifest, 0644)
+	if err != nil {
+		return "", fmt.Errorf("encountered an error when writing the manifest: %w", err)
+	}
+
+	return run(path)
+}
@@ -0,0 +1,294 @@
+package kubernetes
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// TestNamespaceRunLabel is set on every test namespace to the id of the run that created it
+	TestNamespaceRunLabel = "plugin-testing-poc/run-id"
+	// TestNamespaceManagedLabel marks a namespace as created by CreateTestNamespace
+	TestNamespaceManagedLabel = "plugin-testing-poc/test-namespace"
+
+	namespaceSuffixChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	namespaceSuffixLength = 6
+	namespaceCreateTries  = 5
+)
+
+var (
+	runID     string
+	runIDOnce sync.Once
+)
+
+// RunID returns an id that is unique to the current test process. It is the
+// default value of the TestNamespaceRunLabel on namespaces created during the run.
+func RunID() string {
+	runIDOnce.Do(func() {
+		runID = time.Now().UTC().Format("20060102-150405") + "-" + randomString(namespaceSuffixLength)
+	})
+	return runID
+}
+
+// TestNamespaceOptions configures how a test namespace is created and deleted
+type TestNamespaceOptions struct {
+	prefix         string
+	runID          string
+	labels         map[string]string
+	serviceAccount string
+	deleteTimeout  time.Duration
+}
+
+type TestNamespaceOption func(tno *TestNamespaceOptions)
+
+// WithNamespacePrefix sets the prefix of the generated namespace name. Defaults to "e2e".
+func WithNamespacePrefix(prefix string) TestNamespaceOption {
+	return func(tno *TestNamespaceOptions) {
+		tno.prefix = prefix
+	}
+}
+
+// WithRunID sets the value of the TestNamespaceRunLabel. Defaults to RunID().
+func WithRunID(id string) TestNamespaceOption {
+	return func(tno *TestNamespaceOptions) {
+		tno.runID = id
+	}
+}
+
+// WithNamespaceLabels sets extra labels on the namespace, i.e the name of the spec
+func WithNamespaceLabels(labels map[string]string) TestNamespaceOption {
+	return func(tno *TestNamespaceOptions) {
+		for k, v := range labels {
+			tno.labels[k] = v
+		}
+	}
+}
+
+// WithNamespaceServiceAccount sets the ServiceAccount of the scoped Kubectl. Defaults to "default".
+func WithNamespaceServiceAccount(sa string) TestNamespaceOption {
+	return func(tno *TestNamespaceOptions) {
+		tno.serviceAccount = sa
+	}
+}
+
+// WithNamespaceDeleteTimeout sets how long Delete waits for the namespace to be removed
+func WithNamespaceDeleteTimeout(timeout time.Duration) TestNamespaceOption {
+	return func(tno *TestNamespaceOptions) {
+		tno.deleteTimeout = timeout
+	}
+}
+
+// TestNamespace is a uniquely named namespace created for a single spec or test
+type TestNamespace struct {
+	name    string
+	kubectl *KubectlUtil
+	parent  Kubectl
+	opts    *TestNamespaceOptions
+}
+
+// CreateTestNamespace creates a namespace named "<prefix>-<random suffix>" labelled with
+// the run id, so specs running in parallel do not collide. The returned TestNamespace
+// provides a Kubectl scoped to the namespace that shares the command context and
+// kubeconfig of the given Kubectl.
+func CreateTestNamespace(kubectl Kubectl, opts ...TestNamespaceOption) (*TestNamespace, error) {
+	tno := &TestNamespaceOptions{
+		prefix:         "e2e",
+		labels:         make(map[string]string),
+		serviceAccount: "default",
+		deleteTimeout:  2 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(tno)
+	}
+
+	if tno.runID == "" {
+		tno.runID = RunID()
+	}
+
+	labels := map[string]string{
+		TestNamespaceManagedLabel: "true",
+		TestNamespaceRunLabel:     tno.runID,
+	}
+	for k, v := range tno.labels {
+		labels[k] = v
+	}
+
+	var name string
+	var lastErr error
+	for i := 0; i < namespaceCreateTries; i++ {
+		name = tno.prefix + "-" + randomString(namespaceSuffixLength)
+
+		// The namespace is created with its labels so it is never left behind unlabelled
+		manifest, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": labels,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("encountered an error when encoding namespace %q: %w", name, err)
+		}
+
+		var out string
+		out, lastErr = CreateManifest(kubectl, false, manifest)
+		if lastErr == nil {
+			break
+		}
+		if !strings.Contains(out, "AlreadyExists") {
+			return nil, fmt.Errorf("encountered an error when creating namespace %q: %w, output: %s", name, lastErr, out)
+		}
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("encountered an error when creating a unique namespace: %w", lastErr)
+	}
+
+	return &TestNamespace{
+		name:   name,
+		parent: kubectl,
+		opts:   tno,
+		kubectl: NewKubectlUtil(
+			WithCommandContext(kubectl.CommandContext()),
+			WithKubeconfig(KubeconfigOf(kubectl)),
+			WithNamespace(name),
+			WithServiceAccount(tno.serviceAccount),
+		),
+	}, nil
+}
+
+// Name returns the name of the namespace
+func (tn *TestNamespace) Name() string {
+	return tn.name
+}
+
+// Kubectl returns a Kubectl scoped to the namespace
+func (tn *TestNamespace) Kubectl() Kubectl {
+	return tn.kubectl
+}
+
+// Delete deletes the namespace and waits for it to be removed. If resources in the
+// namespace have finalizers that do not complete within the delete timeout the error
+// lists the namespace conditions and the resources that are still remaining.
+func (tn *TestNamespace) Delete() error {
+	out, err := tn.parent.Command("delete", "namespace", tn.name, "--ignore-not-found", "--wait=false")
+	if err != nil {
+		return fmt.Errorf("encountered an error when deleting namespace %q: %w, output: %s", tn.name, err, out)
+	}
+
+	return waitForNamespaceGone(tn.parent, tn.name, tn.opts.deleteTimeout)
+}
+
+// DeleteRunNamespaces deletes every test namespace labelled with the run id, i.e to
+// remove namespaces leaked by an interrupted run, and waits for them to be removed
+func DeleteRunNamespaces(kubectl Kubectl, id string, timeout time.Duration) error {
+	selector := fmt.Sprintf("%s=true,%s=%s", TestNamespaceManagedLabel, TestNamespaceRunLabel, id)
+	out, err := kubectl.Command("get", "namespaces", "-l", selector, "-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return fmt.Errorf("encountered an error when listing the namespaces of run %q: %w, output: %s", id, err, out)
+	}
+
+	names := strings.Fields(out)
+	if len(names) == 0 {
+		return nil
+	}
+
+	out, err = kubectl.Command(append([]string{"delete", "namespace", "--ignore-not-found", "--wait=false"}, names...)...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when deleting the namespaces of run %q: %w, output: %s", id, err, out)
+	}
+
+	var errs []error
+	for _, name := range names {
+		if err := waitForNamespaceGone(kubectl, name, timeout); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func waitForNamespaceGone(kubectl Kubectl, name string, timeout time.Duration) error {
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		out, err := kubectl.Command("get", "namespace", name, "--ignore-not-found", "-o", "name")
+		if err != nil {
+			return false, nil
+		}
+		return strings.TrimSpace(out) == "", nil
+	})
+	if err != nil {
+		return fmt.Errorf("namespace %q was not removed within %s:\n%s", name, timeout, describeTerminatingNamespace(kubectl, name))
+	}
+
+	return nil
+}
+
+// describeTerminatingNamespace returns the namespace conditions that are blocking its
+// removal, i.e NamespaceFinalizersRemaining, and the resources that are left in it
+func describeTerminatingNamespace(kubectl Kubectl, name string) string {
+	var b strings.Builder
+
+	out, err := kubectl.Command("get", "namespace", name, "-o", "jsonpath={.status.conditions}")
+	if err != nil {
+		fmt.Fprintf(&b, "could not get the namespace conditions: %v\n", err)
+	} else {
+		var conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(out), &conditions); err == nil {
+			for _, c := range conditions {
+				if c.Status == "True" {
+					fmt.Fprintf(&b, "\t%s: %s\n", c.Type, c.Message)
+				}
+			}
+		}
+	}
+
+	resources, err := kubectl.Command("api-resources", "--verbs=list", "--namespaced", "-o", "name")
+	if err != nil {
+		fmt.Fprintf(&b, "could not list the namespaced resources: %v\n", err)
+		return b.String()
+	}
+
+	out, err = kubectl.Command("get", strings.Join(strings.Fields(resources), ","), "-n", name,
+		"--ignore-not-found", "-o",
+		`go-template={{ range .items }}{{ .kind }}/{{ .metadata.name }} finalizers: {{ .metadata.finalizers }}{{ "\n" }}{{ end }}`)
+	if err != nil {
+		fmt.Fprintf(&b, "could not list the remaining resources: %v\n", err)
+		return b.String()
+	}
+
+	if strings.TrimSpace(out) != "" {
+		b.WriteString("remaining resources:\n")
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			fmt.Fprintf(&b, "\t%s\n", line)
+		}
+	}
+
+	return b.String()
+}
+
+func randomString(length int) string {
+	max := big.NewInt(int64(len(namespaceSuffixChars)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			// crypto/rand does not fail on supported platforms, fall back to the time
+			n = big.NewInt(time.Now().UnixNano() % max.Int64())
+		}
+		b[i] = namespaceSuffixChars[n.Int64()]
+	}
+	return string(b)
+}
@@ -0,0 +1,143 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// portForwardReadyTimeout is how long to wait for `kubectl port-forward` to start forwarding
+const portForwardReadyTimeout = 30 * time.Second
+
+var forwardingRegexp = regexp.MustCompile(`Forwarding from .*:(\d+) -> \d+`)
+
+// PortForwarder manages a `kubectl port-forward` process running in the background
+type PortForwarder struct {
+	cmd       *exec.Cmd
+	localPort int
+
+	mu     sync.Mutex
+	stderr bytes.Buffer
+	done   chan struct{}
+	err    error
+}
+
+// PortForward starts forwarding a random local port to the port of the target
+// (i.e svc/my-service or pod/my-pod) in the Kubectl namespace. It returns once
+// kubectl reports that it is forwarding. Stop must be called to end the forward.
+func PortForward(kubectl Kubectl, target string, port int) (*PortForwarder, error) {
+	options := []string{"port-forward", "-n", kubectl.Namespace(), target, fmt.Sprintf(":%d", port)}
+	if kubeconfig := KubeconfigOf(kubectl); kubeconfig != "" {
+		options = append([]string{"--kubeconfig", kubeconfig}, options...)
+	}
+
+	ready := make(chan int, 1)
+	pf := &PortForwarder{
+		cmd:  exec.Command("kubectl", options...),
+		done: make(chan struct{}),
+	}
+	pf.cmd.Env = append(os.Environ(), kubectl.CommandContext().Env()...)
+	pf.cmd.Stderr = &lockedWriter{mu: &pf.mu, w: &pf.stderr}
+	pf.cmd.Stdout = &forwardingWriter{ready: ready}
+
+	fmt.Println("Running command:", pf.cmd.String())
+	if err := pf.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("encountered an error when starting port-forward: %w", err)
+	}
+
+	go func() {
+		err := pf.cmd.Wait()
+		pf.mu.Lock()
+		pf.err = err
+		pf.mu.Unlock()
+		close(pf.done)
+	}()
+
+	select {
+	case pf.localPort = <-ready:
+		return pf, nil
+	case <-pf.done:
+		return nil, fmt.Errorf("port-forward to %s exited before it was ready: %v, output: %s", target, pf.exitErr(), pf.output())
+	case <-time.After(portForwardReadyTimeout):
+		_ = pf.Stop()
+		return nil, fmt.Errorf("timed out waiting for port-forward to %s to be ready, output: %s", target, pf.output())
+	}
+}
+
+// LocalPort returns the local port that is being forwarded
+func (pf *PortForwarder) LocalPort() int {
+	return pf.localPort
+}
+
+// Address returns the local address that is being forwarded
+func (pf *PortForwarder) Address() string {
+	return fmt.Sprintf("localhost:%d", pf.localPort)
+}
+
+// Stop stops the port-forward process and waits for it to exit
+func (pf *PortForwarder) Stop() error {
+	select {
+	case <-pf.done:
+		return nil
+	default:
+	}
+
+	if err := pf.cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("encountered an error when stopping port-forward: %w", err)
+	}
+	<-pf.done
+
+	return nil
+}
+
+func (pf *PortForwarder) exitErr() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.err
+}
+
+func (pf *PortForwarder) output() string {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.stderr.String()
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// forwardingWriter reports the local port once kubectl writes that it is forwarding
+type forwardingWriter struct {
+	buf   bytes.Buffer
+	ready chan int
+	found bool
+}
+
+func (fw *forwardingWriter) Write(p []byte) (int, error) {
+	if fw.found {
+		return len(p), nil
+	}
+
+	fw.buf.Write(p)
+	if match := forwardingRegexp.FindSubmatch(fw.buf.Bytes()); match != nil {
+		localPort, err := strconv.Atoi(string(match[1]))
+		if err == nil {
+			fw.found = true
+			fw.ready <- localPort
+		}
+	}
+
+	return len(p), nil
+}
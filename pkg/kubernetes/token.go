@@ -0,0 +1,89 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const legacyTokenSecretTemplate = `apiVersion: v1
+kind: Secret
+metadata:
+  name: %s
+  namespace: %s
+  annotations:
+    kubernetes.io/service-account.name: %s
+type: kubernetes.io/service-account-token
+`
+
+// ServiceAccountToken returns a token for the ServiceAccount in the Kubectl namespace and
+// a func that removes anything created to issue it. On Kubernetes 1.24+ the token is requested
+// with `kubectl create token` using the audience and ttl, if they are set. Older servers do
+// not support this so a ServiceAccount token Secret is created instead and its token is
+// returned, in which case the audience and ttl are not applied and the token is revoked
+// when cleanup deletes the Secret.
+func ServiceAccountToken(kubectl Kubectl, serviceAccount string, audience string, ttl time.Duration) (token string, cleanup func() error, err error) {
+	tokenRequest, err := ServerVersionAtLeast(kubectl, 1, 24)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !tokenRequest {
+		return legacyServiceAccountToken(kubectl, serviceAccount)
+	}
+
+	options := []string{"create", "token", serviceAccount}
+	if audience != "" {
+		options = append(options, "--audience", audience)
+	}
+	if ttl > 0 {
+		options = append(options, "--duration", ttl.String())
+	}
+
+	out, err := OutputInNamespace(kubectl, options...)
+	if err != nil {
+		return "", nil, fmt.Errorf("encountered an error when creating a token for service account %q: %w", serviceAccount, err)
+	}
+
+	return strings.TrimSpace(out), func() error { return nil }, nil
+}
+
+func legacyServiceAccountToken(kubectl Kubectl, serviceAccount string) (string, func() error, error) {
+	name := serviceAccount + "-e2e-token"
+	cleanup := func() error {
+		out, err := kubectl.Delete(true, "secret", name, "--ignore-not-found")
+		if err != nil {
+			return fmt.Errorf("encountered an error when deleting the token secret: %w, output: %s", err, out)
+		}
+		return nil
+	}
+
+	manifest := fmt.Sprintf(legacyTokenSecretTemplate, name, kubectl.Namespace(), serviceAccount)
+	out, err := ApplyManifest(kubectl, true, []byte(manifest))
+	if err != nil {
+		return "", cleanup, fmt.Errorf("encountered an error when creating the token secret: %w, output: %s", err, out)
+	}
+
+	// The token controller populates the token after the secret is created
+	var b64Token string
+	err = wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		b64Token, err = OutputInNamespace(kubectl, "get", "secret", name, "-o", "jsonpath={.data.token}")
+		if err != nil {
+			return false, nil
+		}
+		return strings.TrimSpace(b64Token) != "", nil
+	})
+	if err != nil {
+		return "", cleanup, fmt.Errorf("encountered an error when waiting for the token secret to be populated: %w", err)
+	}
+
+	token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64Token))
+	if err != nil {
+		return "", cleanup, fmt.Errorf("encountered an error when decoding the token: %w", err)
+	}
+
+	return string(token), cleanup, nil
+}
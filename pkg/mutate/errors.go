@@ -0,0 +1,24 @@
+package mutate
+
+import "fmt"
+
+// TargetNotFoundError is returned when the declaration, field or key an edit
+// is made relative to does not exist in the file
+type TargetNotFoundError struct {
+	File   string
+	Target string
+}
+
+func (e *TargetNotFoundError) Error() string {
+	return fmt.Sprintf("could not find %s in %s", e.Target, e.File)
+}
+
+// AlreadyExistsError is returned when an edit would add something that is already present
+type AlreadyExistsError struct {
+	File   string
+	Target string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("%s already exists in %s", e.Target, e.File)
+}
@@ -0,0 +1,426 @@
+// Package mutate edits scaffolded files relative to their structure instead of exact
+// strings, so edits keep working when the comments generated by a plugin change.
+package mutate
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GoFile is a Go source file that is edited in memory. Declarations are located with
+// go/ast and the source is changed around them, so existing comments are preserved.
+// The source is formatted after every edit.
+type GoFile struct {
+	path string
+	src  []byte
+	fset *token.FileSet
+	file *ast.File
+}
+
+// StructField is a field added to a struct with AddStructField
+type StructField struct {
+	Name string
+	// Type is the Go type of the field, i.e []string or *metav1.Time
+	Type string
+	// Tag is the struct tag without backquotes, i.e json:"size,omitempty"
+	Tag string
+	// Doc is the doc comment of the field, one line per entry, without the leading //
+	Doc []string
+	// Markers are added after the doc comment, i.e +kubebuilder:validation:Minimum=0
+	Markers []string
+}
+
+// RBACRule is a kubebuilder RBAC marker added with AddRBACMarkers
+type RBACRule struct {
+	Groups    []string
+	Resources []string
+	Verbs     []string
+}
+
+// Marker returns the rule as a kubebuilder RBAC marker
+func (r RBACRule) Marker() string {
+	return fmt.Sprintf("+kubebuilder:rbac:groups=%s,resources=%s,verbs=%s",
+		strings.Join(r.Groups, ";"), strings.Join(r.Resources, ";"), strings.Join(r.Verbs, ";"))
+}
+
+// LoadGoFile parses the Go file at path
+func LoadGoFile(path string) (*GoFile, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading %q: %w", path, err)
+	}
+
+	gf := &GoFile{path: path}
+	if err := gf.parse(src); err != nil {
+		return nil, err
+	}
+
+	return gf, nil
+}
+
+// Source returns the current source of the file
+func (gf *GoFile) Source() []byte {
+	return gf.src
+}
+
+// Save writes the edited source back to the file
+func (gf *GoFile) Save() error {
+	if err := os.WriteFile(gf.path, gf.src, 0644); err != nil {
+		return fmt.Errorf("encountered an error when writing %q: %w", gf.path, err)
+	}
+
+	return nil
+}
+
+// AddImport adds the import with an optional name. Nothing is changed if the path is
+// already imported with the same name. An AlreadyExistsError is returned if it is imported
+// with a different name, use SetImportName to rename it instead.
+func (gf *GoFile) AddImport(name string, path string) error {
+	if spec := gf.findImport(path); spec != nil {
+		if importName(spec) == name {
+			return nil
+		}
+		return &AlreadyExistsError{File: gf.path, Target: fmt.Sprintf("import %q with a different name", path)}
+	}
+
+	line := strconv.Quote(path)
+	if name != "" {
+		line = name + " " + line
+	}
+
+	for _, decl := range gf.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT || !gen.Lparen.IsValid() {
+			continue
+		}
+		return gf.splice(gf.offset(gen.Rparen), gf.offset(gen.Rparen), "\t"+line+"\n")
+	}
+
+	// No grouped import declaration, add a new one after the existing imports or package clause
+	pos := gf.file.Name.End()
+	for _, imp := range gf.file.Imports {
+		if imp.End() > pos {
+			pos = imp.End()
+		}
+	}
+
+	return gf.splice(gf.offset(pos), gf.offset(pos), "\n\nimport "+line+"\n")
+}
+
+// SetImportName changes the name the path is imported as, i.e to alias an import that
+// conflicts with a variable in an inserted function body. Uses of the package are not renamed.
+func (gf *GoFile) SetImportName(path string, name string) error {
+	spec := gf.findImport(path)
+	if spec == nil {
+		return &TargetNotFoundError{File: gf.path, Target: fmt.Sprintf("import %q", path)}
+	}
+
+	line := strconv.Quote(path)
+	if name != "" {
+		line = name + " " + line
+	}
+
+	return gf.splice(gf.offset(spec.Pos()), gf.offset(spec.Path.End()), line)
+}
+
+// AddStructField adds the field at the end of the named struct type
+func (gf *GoFile) AddStructField(typeName string, field StructField) error {
+	st := gf.findStruct(typeName)
+	if st == nil {
+		return &TargetNotFoundError{File: gf.path, Target: fmt.Sprintf("struct %s", typeName)}
+	}
+
+	for _, f := range st.Fields.List {
+		for _, name := range f.Names {
+			if name.Name == field.Name {
+				return &AlreadyExistsError{File: gf.path, Target: fmt.Sprintf("field %s.%s", typeName, field.Name)}
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\n")
+	for _, doc := range field.Doc {
+		b.WriteString("// " + doc + "\n")
+	}
+	for _, marker := range field.Markers {
+		b.WriteString(markerComment(marker) + "\n")
+	}
+	b.WriteString(field.Name + " " + field.Type)
+	if field.Tag != "" {
+		b.WriteString(" `" + field.Tag + "`")
+	}
+	b.WriteString("\n")
+
+	return gf.splice(gf.offset(st.Fields.Closing), gf.offset(st.Fields.Closing), b.String())
+}
+
+// AddTypeMarkers adds markers, i.e +kubebuilder:subresource:status, to the doc comment
+// of the named type. Markers that are already present are skipped.
+func (gf *GoFile) AddTypeMarkers(typeName string, markers ...string) error {
+	gen, spec := gf.findType(typeName)
+	if spec == nil {
+		return &TargetNotFoundError{File: gf.path, Target: fmt.Sprintf("type %s", typeName)}
+	}
+
+	doc := gen.Doc
+	if spec.Doc != nil {
+		doc = spec.Doc
+	}
+
+	return gf.addMarkers(gen.Pos(), doc, markers)
+}
+
+// SetFunctionBody replaces the body of the function. Methods are named "<Type>.<Method>",
+// i.e MemcachedReconciler.Reconcile. The body is the code between the braces.
+func (gf *GoFile) SetFunctionBody(funcName string, body string) error {
+	fn := gf.findFunc(funcName)
+	if fn == nil || fn.Body == nil {
+		return &TargetNotFoundError{File: gf.path, Target: fmt.Sprintf("function %s", funcName)}
+	}
+
+	return gf.splice(gf.offset(fn.Body.Lbrace)+1, gf.offset(fn.Body.Rbrace), "\n"+strings.Trim(body, "\n")+"\n")
+}
+
+// AddDecls appends declarations, i.e helper functions, to the end of the file. It
+// returns an error if any of the declared functions or types already exist.
+func (gf *GoFile) AddDecls(src string) error {
+	decls, err := parser.ParseFile(token.NewFileSet(), gf.path, "package p\n"+src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("encountered an error when parsing the declarations to add to %s: %w", gf.path, err)
+	}
+
+	for _, decl := range decls.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if gf.findFunc(funcDeclName(d)) != nil {
+				return &AlreadyExistsError{File: gf.path, Target: fmt.Sprintf("function %s", funcDeclName(d))}
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					if _, existing := gf.findType(ts.Name.Name); existing != nil {
+						return &AlreadyExistsError{File: gf.path, Target: fmt.Sprintf("type %s", ts.Name.Name)}
+					}
+				}
+			}
+		}
+	}
+
+	end := len(gf.src)
+	return gf.splice(end, end, "\n"+strings.Trim(src, "\n")+"\n")
+}
+
+// AddRBACMarkers adds kubebuilder RBAC markers above the function, next to any RBAC
+// markers that are already there. Markers that are already present are skipped.
+func (gf *GoFile) AddRBACMarkers(funcName string, rules ...RBACRule) error {
+	markers := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		markers = append(markers, rule.Marker())
+	}
+
+	return gf.AddFunctionMarkers(funcName, markers...)
+}
+
+// AddFunctionMarkers adds markers above the function. Methods are named "<Type>.<Method>".
+func (gf *GoFile) AddFunctionMarkers(funcName string, markers ...string) error {
+	fn := gf.findFunc(funcName)
+	if fn == nil {
+		return &TargetNotFoundError{File: gf.path, Target: fmt.Sprintf("function %s", funcName)}
+	}
+
+	return gf.addMarkers(fn.Pos(), fn.Doc, markers)
+}
+
+// addMarkers inserts the markers for the declaration at pos. If the declaration is
+// directly preceded by a group of markers, i.e the RBAC markers kubebuilder scaffolds
+// above Reconcile, the markers are appended to that group. Otherwise they are added
+// to the end of the doc comment. Only markers already in the doc comment or that
+// group are skipped, the same marker on another declaration does not count.
+func (gf *GoFile) addMarkers(pos token.Pos, doc *ast.CommentGroup, markers []string) error {
+	start := pos
+	if doc != nil {
+		start = doc.Pos()
+	}
+	group := gf.markerGroupBefore(start)
+
+	var missing []string
+	for _, marker := range markers {
+		comment := markerComment(marker)
+		if !hasComment(comment, doc, group) {
+			missing = append(missing, comment)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	text := strings.Join(missing, "\n") + "\n"
+
+	if group != nil {
+		offset := gf.offset(group.End())
+		return gf.splice(offset, offset, "\n"+strings.TrimSuffix(text, "\n"))
+	}
+
+	// Insert as the last lines of the doc comment, directly above the declaration
+	offset := gf.lineStart(gf.offset(pos))
+	return gf.splice(offset, offset, text)
+}
+
+// markerGroupBefore returns the comment group that ends on the line before the start of a
+// declaration's doc, separated by at most one blank line, if it only contains markers
+func (gf *GoFile) markerGroupBefore(start token.Pos) *ast.CommentGroup {
+	startLine := gf.fset.Position(start).Line
+
+	var before *ast.CommentGroup
+	for _, group := range gf.file.Comments {
+		if group.End() >= start {
+			break
+		}
+		before = group
+	}
+	if before == nil || startLine-gf.fset.Position(before.End()).Line > 2 {
+		return nil
+	}
+
+	for _, comment := range before.List {
+		if !strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(comment.Text, "//")), "+") {
+			return nil
+		}
+	}
+
+	// Make sure no declaration sits between the markers and the start
+	for _, decl := range gf.file.Decls {
+		if decl.Pos() > before.End() && decl.Pos() < start {
+			return nil
+		}
+	}
+
+	return before
+}
+
+// hasComment returns true if any of the comment groups contains the comment, ignoring spaces
+func hasComment(text string, groups ...*ast.CommentGroup) bool {
+	normalized := strings.ReplaceAll(text, " ", "")
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, comment := range group.List {
+			if strings.ReplaceAll(comment.Text, " ", "") == normalized {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (gf *GoFile) findImport(path string) *ast.ImportSpec {
+	for _, imp := range gf.file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == path {
+			return imp
+		}
+	}
+	return nil
+}
+
+func (gf *GoFile) findType(name string) (*ast.GenDecl, *ast.TypeSpec) {
+	for _, decl := range gf.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == name {
+				return gen, ts
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (gf *GoFile) findStruct(name string) *ast.StructType {
+	_, spec := gf.findType(name)
+	if spec == nil {
+		return nil
+	}
+	st, _ := spec.Type.(*ast.StructType)
+	return st
+}
+
+func (gf *GoFile) findFunc(name string) *ast.FuncDecl {
+	for _, decl := range gf.file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && funcDeclName(fn) == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// funcDeclName returns the name of a function, or "<Type>.<Method>" for a method
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name == nil {
+		return ""
+	}
+	return spec.Name.Name
+}
+
+// markerComment returns the marker as a comment in the //+marker form kubebuilder scaffolds
+func markerComment(marker string) string {
+	return "//" + strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(marker), "//"))
+}
+
+func (gf *GoFile) offset(pos token.Pos) int {
+	return gf.fset.Position(pos).Offset
+}
+
+func (gf *GoFile) lineStart(offset int) int {
+	return bytes.LastIndexByte(gf.src[:offset], '\n') + 1
+}
+
+// splice replaces src[start:end] with text, then formats and reparses the result
+func (gf *GoFile) splice(start int, end int, text string) error {
+	src := make([]byte, 0, len(gf.src)+len(text))
+	src = append(src, gf.src[:start]...)
+	src = append(src, text...)
+	src = append(src, gf.src[end:]...)
+
+	return gf.parse(src)
+}
+
+func (gf *GoFile) parse(src []byte) error {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("encountered an error when formatting %s, the edit produced invalid Go: %w", gf.path, err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, gf.path, formatted, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("encountered an error when parsing %s: %w", gf.path, err)
+	}
+
+	gf.src, gf.fset, gf.file = formatted, fset, file
+	return nil
+}
@@ -0,0 +1,333 @@
+package mutate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTypesFile = `package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MemcachedSpec defines the desired state of Memcached
+type MemcachedSpec struct {
+	// Foo is an example field of Memcached
+	Foo string ` + "`json:\"foo,omitempty\"`" + `
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// Memcached is the Schema for the memcacheds API
+type Memcached struct {
+	metav1.TypeMeta   ` + "`json:\",inline\"`" + `
+	metav1.ObjectMeta ` + "`json:\"metadata,omitempty\"`" + `
+
+	Spec MemcachedSpec ` + "`json:\"spec,omitempty\"`" + `
+}
+
+//+kubebuilder:object:root=true
+
+// MemcachedList contains a list of Memcached
+type MemcachedList struct {
+	metav1.TypeMeta ` + "`json:\",inline\"`" + `
+	metav1.ListMeta ` + "`json:\"metadata,omitempty\"`" + `
+	Items           []Memcached ` + "`json:\"items\"`" + `
+}
+`
+
+const testControllerFile = `package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// MemcachedReconciler reconciles a Memcached object
+type MemcachedReconciler struct{}
+
+//+kubebuilder:rbac:groups=cache.example.com,resources=memcacheds,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop
+func (r *MemcachedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MemcachedReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return nil
+}
+`
+
+func TestGoFileEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		edit     func(gf *GoFile) error
+		expected string
+		// err is checked with errors.As when the edit is expected to fail
+		err interface{}
+	}{
+		{
+			name: "add a named import to the import group",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error { return gf.AddImport("appsv1", "k8s.io/api/apps/v1") },
+			expected: `package controllers
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+` + testControllerFile[len("package controllers\n\nimport (\n\t\"context\"\n\n\tctrl \"sigs.k8s.io/controller-runtime\"\n)\n"):],
+		},
+		{
+			name:     "add an import that already exists with the same name",
+			src:      testControllerFile,
+			edit:     func(gf *GoFile) error { return gf.AddImport("ctrl", "sigs.k8s.io/controller-runtime") },
+			expected: testControllerFile,
+		},
+		{
+			name: "add an import that already exists with another name",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error { return gf.AddImport("", "sigs.k8s.io/controller-runtime") },
+			err:  new(*AlreadyExistsError),
+		},
+		{
+			name: "add an import to a file without imports",
+			src:  "package p\n\nfunc f() {}\n",
+			edit: func(gf *GoFile) error { return gf.AddImport("", "errors") },
+			expected: `package p
+
+import "errors"
+
+func f() {}
+`,
+		},
+		{
+			name: "rename an import",
+			src:  "package p\n\nimport \"sigs.k8s.io/controller-runtime/pkg/log\"\n\nvar _ = log.Log\n",
+			edit: func(gf *GoFile) error {
+				return gf.SetImportName("sigs.k8s.io/controller-runtime/pkg/log", "ctrllog")
+			},
+			expected: "package p\n\nimport ctrllog \"sigs.k8s.io/controller-runtime/pkg/log\"\n\nvar _ = log.Log\n",
+		},
+		{
+			name: "rename a missing import",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error { return gf.SetImportName("errors", "stderrors") },
+			err:  new(*TargetNotFoundError),
+		},
+		{
+			name: "add a struct field with doc and markers",
+			src:  "package p\n\ntype MemcachedSpec struct {\n\tFoo string\n}\n",
+			edit: func(gf *GoFile) error {
+				return gf.AddStructField("MemcachedSpec", StructField{
+					Name:    "Size",
+					Type:    "int32",
+					Tag:     `json:"size,omitempty"`,
+					Doc:     []string{"Size is the number of instances"},
+					Markers: []string{"+kubebuilder:validation:Minimum=0"},
+				})
+			},
+			expected: "package p\n\ntype MemcachedSpec struct {\n\tFoo string\n\n\t// Size is the number of instances\n\t//+kubebuilder:validation:Minimum=0\n\tSize int32 `json:\"size,omitempty\"`\n}\n",
+		},
+		{
+			name: "add a struct field that already exists",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddStructField("MemcachedSpec", StructField{Name: "Foo", Type: "string"})
+			},
+			err: new(*AlreadyExistsError),
+		},
+		{
+			name: "add a struct field to a missing struct",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddStructField("MemcachedStatus", StructField{Name: "Nodes", Type: "[]string"})
+			},
+			err: new(*TargetNotFoundError),
+		},
+		{
+			name: "add a type marker to the marker group",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddTypeMarkers("Memcached", "+kubebuilder:printcolumn:name=Size,type=integer,JSONPath=`.spec.size`")
+			},
+			expected: replaceOnce(testTypesFile,
+				"//+kubebuilder:subresource:status\n",
+				"//+kubebuilder:subresource:status\n//+kubebuilder:printcolumn:name=Size,type=integer,JSONPath=`.spec.size`\n"),
+		},
+		{
+			name: "add a type marker that already exists on the type",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddTypeMarkers("Memcached", "+kubebuilder:subresource:status", "//+kubebuilder:object:root=true")
+			},
+			expected: testTypesFile,
+		},
+		{
+			name: "add a type marker that already exists on a different type",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error { return gf.AddTypeMarkers("MemcachedList", "+kubebuilder:subresource:status") },
+			expected: replaceOnce(testTypesFile,
+				"//+kubebuilder:object:root=true\n\n// MemcachedList",
+				"//+kubebuilder:object:root=true\n//+kubebuilder:subresource:status\n\n// MemcachedList"),
+		},
+		{
+			name: "add a type marker to a type without a marker group",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddTypeMarkers("MemcachedSpec", "+kubebuilder:validation:XValidation")
+			},
+			expected: replaceOnce(testTypesFile,
+				"// MemcachedSpec defines the desired state of Memcached\n",
+				"// MemcachedSpec defines the desired state of Memcached\n// +kubebuilder:validation:XValidation\n"),
+		},
+		{
+			name: "add RBAC markers next to the scaffolded ones",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddRBACMarkers("MemcachedReconciler.Reconcile",
+					RBACRule{Groups: []string{"cache.example.com"}, Resources: []string{"memcacheds"}, Verbs: []string{"get", "list", "watch"}},
+					RBACRule{Groups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "create"}},
+				)
+			},
+			expected: replaceOnce(testControllerFile,
+				"verbs=get;list;watch\n",
+				"verbs=get;list;watch\n//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;create\n"),
+		},
+		{
+			name: "add a function marker that exists on another function",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddFunctionMarkers("MemcachedReconciler.SetupWithManager",
+					"+kubebuilder:rbac:groups=cache.example.com,resources=memcacheds,verbs=get;list;watch")
+			},
+			expected: replaceOnce(testControllerFile,
+				"// SetupWithManager sets up the controller with the Manager.\n",
+				"// SetupWithManager sets up the controller with the Manager.\n// +kubebuilder:rbac:groups=cache.example.com,resources=memcacheds,verbs=get;list;watch\n"),
+		},
+		{
+			name: "set the body of a method",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error {
+				return gf.SetFunctionBody("MemcachedReconciler.SetupWithManager", "return ctrl.NewControllerManagedBy(mgr).Complete(r)")
+			},
+			expected: replaceOnce(testControllerFile,
+				"func (r *MemcachedReconciler) SetupWithManager(mgr ctrl.Manager) error {\n\treturn nil\n",
+				"func (r *MemcachedReconciler) SetupWithManager(mgr ctrl.Manager) error {\n\treturn ctrl.NewControllerManagedBy(mgr).Complete(r)\n"),
+		},
+		{
+			name: "set the body of a missing function",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error { return gf.SetFunctionBody("Reconcile", "return nil") },
+			err:  new(*TargetNotFoundError),
+		},
+		{
+			name: "add declarations",
+			src:  "package p\n\nfunc f() {}\n",
+			edit: func(gf *GoFile) error { return gf.AddDecls("\n// g is added\nfunc g() {}\n") },
+			expected: `package p
+
+func f() {}
+
+// g is added
+func g() {}
+`,
+		},
+		{
+			name: "add a function that already exists",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error {
+				return gf.AddDecls("func (r *MemcachedReconciler) Reconcile() {}")
+			},
+			err: new(*AlreadyExistsError),
+		},
+		{
+			name: "add a type that already exists",
+			src:  testTypesFile,
+			edit: func(gf *GoFile) error { return gf.AddDecls("type MemcachedList struct{}") },
+			err:  new(*AlreadyExistsError),
+		},
+		{
+			name: "edit producing invalid Go",
+			src:  testControllerFile,
+			edit: func(gf *GoFile) error { return gf.AddDecls("func broken( {") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gf := loadTestGoFile(t, tt.src)
+
+			err := tt.edit(gf)
+			if tt.expected == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got:\n%s", gf.Source())
+				}
+				if tt.err != nil && !errors.As(err, tt.err) {
+					t.Errorf("got error %T %v, want %T", err, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(gf.Source()) != tt.expected {
+				t.Errorf("got:\n%s\nwant:\n%s", gf.Source(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestGoFileSave(t *testing.T) {
+	gf := loadTestGoFile(t, "package p\n\nfunc f() {}\n")
+	if err := gf.AddImport("", "errors"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gf.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	saved, err := os.ReadFile(gf.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != string(gf.Source()) {
+		t.Errorf("got:\n%s\nwant:\n%s", saved, gf.Source())
+	}
+}
+
+func loadTestGoFile(t *testing.T, src string) *GoFile {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "file.go")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gf, err := LoadGoFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading the file: %v", err)
+	}
+
+	return gf
+}
+
+// replaceOnce replaces old in s, which must occur exactly once so edits to the test sources are noticed
+func replaceOnce(s string, old string, new string) string {
+	if n := strings.Count(s, old); n != 1 {
+		panic(fmt.Sprintf("%q occurs %d times, expected once", old, n))
+	}
+	return strings.Replace(s, old, new, 1)
+}
@@ -0,0 +1,193 @@
+package mutate
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// YAMLFile is a single document YAML file that is edited as data. Fields are addressed
+// by path, where a numeric element indexes into a list, i.e "spec", "containers", "0".
+// Comments are not preserved when the file is saved.
+type YAMLFile struct {
+	path string
+	data interface{}
+}
+
+// LoadYAMLFile decodes the YAML file at path
+func LoadYAMLFile(path string) (*YAMLFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading %q: %w", path, err)
+	}
+
+	yf := &YAMLFile{path: path}
+	if err := yaml.Unmarshal(b, &yf.data); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding %q: %w", path, err)
+	}
+	if yf.data == nil {
+		yf.data = map[string]interface{}{}
+	}
+
+	return yf, nil
+}
+
+// Get returns the value at the path
+func (yf *YAMLFile) Get(fields ...string) (interface{}, error) {
+	value := yf.data
+	for i, field := range fields {
+		next, ok := child(value, field)
+		if !ok {
+			return nil, yf.notFound(fields[:i+1])
+		}
+		value = next
+	}
+
+	return value, nil
+}
+
+// Set sets the value at the path, creating any missing maps along the way.
+// Lists are not created, so indexes must refer to existing elements.
+func (yf *YAMLFile) Set(value interface{}, fields ...string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no field given to set in %s", yf.path)
+	}
+
+	parent, err := yf.parent(fields, true)
+	if err != nil {
+		return err
+	}
+
+	last := fields[len(fields)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+	case []interface{}:
+		index, err := strconv.Atoi(last)
+		if err != nil || index < 0 || index >= len(p) {
+			return yf.notFound(fields)
+		}
+		p[index] = value
+	default:
+		return fmt.Errorf("%s in %s is not a map or list", strings.Join(fields[:len(fields)-1], "."), yf.path)
+	}
+
+	return nil
+}
+
+// Append appends the value to the list at the path, creating the list if it does not exist
+func (yf *YAMLFile) Append(value interface{}, fields ...string) error {
+	existing, err := yf.Get(fields...)
+	if err != nil {
+		existing = nil
+	}
+
+	var list []interface{}
+	if existing != nil {
+		var ok bool
+		if list, ok = existing.([]interface{}); !ok {
+			return fmt.Errorf("%s in %s is not a list", strings.Join(fields, "."), yf.path)
+		}
+	}
+
+	return yf.Set(append(list, value), fields...)
+}
+
+// Remove removes the value at the path. It returns an error if the path does not exist.
+func (yf *YAMLFile) Remove(fields ...string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no field given to remove in %s", yf.path)
+	}
+
+	parent, err := yf.parent(fields, false)
+	if err != nil {
+		return err
+	}
+
+	last := fields[len(fields)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[last]; !ok {
+			return yf.notFound(fields)
+		}
+		delete(p, last)
+		return nil
+	case []interface{}:
+		index, err := strconv.Atoi(last)
+		if err != nil || index < 0 || index >= len(p) {
+			return yf.notFound(fields)
+		}
+		// Lists can't be shrunk in place, so set the shortened list on its parent
+		return yf.Set(append(p[:index:index], p[index+1:]...), fields[:len(fields)-1]...)
+	default:
+		return yf.notFound(fields)
+	}
+}
+
+// YAML returns the document encoded as YAML
+func (yf *YAMLFile) YAML() ([]byte, error) {
+	b, err := yaml.Marshal(yf.data)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when encoding %s: %w", yf.path, err)
+	}
+
+	return b, nil
+}
+
+// Save writes the document back to the file
+func (yf *YAMLFile) Save() error {
+	b, err := yf.YAML()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(yf.path, b, 0644); err != nil {
+		return fmt.Errorf("encountered an error when writing %q: %w", yf.path, err)
+	}
+
+	return nil
+}
+
+// parent returns the container of the last field in the path. If create is set
+// missing or null maps along the path are created.
+func (yf *YAMLFile) parent(fields []string, create bool) (interface{}, error) {
+	value := yf.data
+	for i, field := range fields[:len(fields)-1] {
+		next, ok := child(value, field)
+		if !ok || next == nil {
+			m, isMap := value.(map[string]interface{})
+			if !create || !isMap {
+				return nil, yf.notFound(fields[:i+1])
+			}
+			next = map[string]interface{}{}
+			m[field] = next
+		}
+		value = next
+	}
+
+	return value, nil
+}
+
+func (yf *YAMLFile) notFound(fields []string) error {
+	return &TargetNotFoundError{File: yf.path, Target: strings.Join(fields, ".")}
+}
+
+// child returns the element of a map or list addressed by field
+func child(value interface{}, field string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[field]
+		return next, ok
+	case []interface{}:
+		index, err := strconv.Atoi(field)
+		if err != nil || index < 0 || index >= len(v) {
+			return nil, false
+		}
+		return v[index], true
+	default:
+		return nil, false
+	}
+}
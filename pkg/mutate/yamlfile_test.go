@@ -0,0 +1,228 @@
+package mutate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const testManagerYAML = `# manager deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller-manager
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        args:
+        - --leader-elect
+      - name: kube-rbac-proxy
+`
+
+func TestYAMLFileEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(yf *YAMLFile) error
+		expected string
+		wantErr  bool
+	}{
+		{
+			name: "set a nested field through a list index",
+			edit: func(yf *YAMLFile) error {
+				return yf.Set("controller:latest", "spec", "template", "spec", "containers", "0", "image")
+			},
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: controller-manager}
+spec:
+  template:
+    spec:
+      containers:
+      - {name: manager, args: [--leader-elect], image: "controller:latest"}
+      - {name: kube-rbac-proxy}
+`,
+		},
+		{
+			name: "set creates missing maps",
+			edit: func(yf *YAMLFile) error {
+				return yf.Set("memcached", "metadata", "labels", "app")
+			},
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: controller-manager, labels: {app: memcached}}
+spec:
+  template:
+    spec:
+      containers:
+      - {name: manager, args: [--leader-elect]}
+      - {name: kube-rbac-proxy}
+`,
+		},
+		{
+			name: "set does not create list elements",
+			edit: func(yf *YAMLFile) error {
+				return yf.Set("proxy", "spec", "template", "spec", "containers", "2", "name")
+			},
+			wantErr: true,
+		},
+		{
+			name: "append to an existing list",
+			edit: func(yf *YAMLFile) error {
+				return yf.Append("--metrics-bind-address=:8080", "spec", "template", "spec", "containers", "0", "args")
+			},
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: controller-manager}
+spec:
+  template:
+    spec:
+      containers:
+      - {name: manager, args: [--leader-elect, "--metrics-bind-address=:8080"]}
+      - {name: kube-rbac-proxy}
+`,
+		},
+		{
+			name: "append creates a missing list",
+			edit: func(yf *YAMLFile) error {
+				return yf.Append("--secure-listen-address=0.0.0.0:8443", "spec", "template", "spec", "containers", "1", "args")
+			},
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: controller-manager}
+spec:
+  template:
+    spec:
+      containers:
+      - {name: manager, args: [--leader-elect]}
+      - {name: kube-rbac-proxy, args: [--secure-listen-address=0.0.0.0:8443]}
+`,
+		},
+		{
+			name: "append to a field that is not a list",
+			edit: func(yf *YAMLFile) error {
+				return yf.Append("value", "metadata", "name")
+			},
+			wantErr: true,
+		},
+		{
+			name: "remove a list element",
+			edit: func(yf *YAMLFile) error {
+				return yf.Remove("spec", "template", "spec", "containers", "1")
+			},
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: controller-manager}
+spec:
+  template:
+    spec:
+      containers:
+      - {name: manager, args: [--leader-elect]}
+`,
+		},
+		{
+			name: "remove a map key",
+			edit: func(yf *YAMLFile) error {
+				return yf.Remove("spec", "template", "spec", "containers", "0", "args")
+			},
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: controller-manager}
+spec:
+  template:
+    spec:
+      containers:
+      - {name: manager}
+      - {name: kube-rbac-proxy}
+`,
+		},
+		{
+			name: "remove a missing field",
+			edit: func(yf *YAMLFile) error {
+				return yf.Remove("spec", "replicas")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yf := loadTestYAMLFile(t, testManagerYAML)
+
+			err := tt.edit(yf)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := yf.Save(); err != nil {
+				t.Fatalf("unexpected error saving: %v", err)
+			}
+
+			b, err := os.ReadFile(yf.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, expected interface{}
+			if err := yaml.Unmarshal(b, &got); err != nil {
+				t.Fatalf("saved file is not valid YAML: %v", err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatalf("invalid expected YAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("got:\n%s\nwant:\n%s", b, tt.expected)
+			}
+		})
+	}
+}
+
+func TestYAMLFileGet(t *testing.T) {
+	yf := loadTestYAMLFile(t, testManagerYAML)
+
+	name, err := yf.Get("spec", "template", "spec", "containers", "1", "name")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name != "kube-rbac-proxy" {
+		t.Errorf("got %v, want kube-rbac-proxy", name)
+	}
+
+	_, err = yf.Get("spec", "template", "spec", "containers", "manager")
+	var notFound *TargetNotFoundError
+	if !errors.As(err, &notFound) || notFound.Target != "spec.template.spec.containers.manager" {
+		t.Errorf("got error %v, want a TargetNotFoundError for spec.template.spec.containers.manager", err)
+	}
+}
+
+func loadTestYAMLFile(t *testing.T, content string) *YAMLFile {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "manager.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	yf, err := LoadYAMLFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading the file: %v", err)
+	}
+
+	return yf
+}
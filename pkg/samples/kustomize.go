@@ -0,0 +1,344 @@
+package samples
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultKustomizeDir is the kustomize directory that `make deploy` builds
+	DefaultKustomizeDir = "config/default"
+	// ManifestsKustomizeDir is the kustomize directory used to generate bundles
+	ManifestsKustomizeDir = "config/manifests"
+)
+
+// certManagerVars returns the vars the scaffolded config/default/kustomization.yaml
+// needs so the certmanager and webhook overlays can reference each other
+func certManagerVars() []map[string]interface{} {
+	return []map[string]interface{}{
+		kustomizeVar("CERTIFICATE_NAMESPACE", "Certificate", "cert-manager.io", "v1", "serving-cert", "metadata.namespace"),
+		kustomizeVar("CERTIFICATE_NAME", "Certificate", "cert-manager.io", "v1", "serving-cert", ""),
+		kustomizeVar("SERVICE_NAMESPACE", "Service", "", "v1", "webhook-service", "metadata.namespace"),
+		kustomizeVar("SERVICE_NAME", "Service", "", "v1", "webhook-service", ""),
+	}
+}
+
+// PatchTarget selects the resources a patch is applied to
+type PatchTarget struct {
+	Group     string
+	Version   string
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// Kustomization is an editable kustomization.yaml of a Sample. Edits are made to the
+// decoded document rather than the text, so comments are not preserved when saved.
+type Kustomization struct {
+	sample Sample
+	dir    string
+	data   map[string]interface{}
+}
+
+// Kustomize loads the kustomization.yaml in the directory, relative to the root of the
+// Sample, i.e DefaultKustomizeDir
+func Kustomize(sample Sample, dir string) (*Kustomization, error) {
+	k := &Kustomization{sample: sample, dir: dir}
+
+	b, err := os.ReadFile(k.Path())
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading %q: %w", k.Path(), err)
+	}
+
+	if err := yaml.Unmarshal(b, &k.data); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding %q: %w", k.Path(), err)
+	}
+	if k.data == nil {
+		k.data = make(map[string]interface{})
+	}
+
+	return k, nil
+}
+
+// Path returns the path of the kustomization.yaml
+func (k *Kustomization) Path() string {
+	return filepath.Join(sampleDir(k.sample), k.dir, "kustomization.yaml")
+}
+
+// Data returns the decoded kustomization, which can be edited directly for fields
+// that have no helper
+func (k *Kustomization) Data() map[string]interface{} {
+	return k.data
+}
+
+// SetNamespace sets the namespace added to all resources
+func (k *Kustomization) SetNamespace(namespace string) *Kustomization {
+	k.data["namespace"] = namespace
+	return k
+}
+
+// SetNamePrefix sets the prefix added to the names of all resources
+func (k *Kustomization) SetNamePrefix(prefix string) *Kustomization {
+	k.data["namePrefix"] = prefix
+	return k
+}
+
+// AddResources adds the resources, or overlays such as ../webhook, that are not already
+// present. The scaffolded `bases` field is used when it exists, `resources` otherwise.
+func (k *Kustomization) AddResources(resources ...string) *Kustomization {
+	field := "resources"
+	if _, ok := k.data["bases"]; ok {
+		field = "bases"
+	}
+
+	k.appendUnique(field, resources...)
+	return k
+}
+
+// RemoveResources removes the resources from both `bases` and `resources`
+func (k *Kustomization) RemoveResources(resources ...string) *Kustomization {
+	for _, field := range []string{"bases", "resources"} {
+		k.remove(field, resources...)
+	}
+	return k
+}
+
+// AddPatchesStrategicMerge adds strategic merge patch files that are not already present
+func (k *Kustomization) AddPatchesStrategicMerge(paths ...string) *Kustomization {
+	k.appendUnique("patchesStrategicMerge", paths...)
+	return k
+}
+
+// AddPatch adds a patch file, which can be a strategic merge or JSON6902 patch,
+// applied to the resources selected by the target. A nil target uses the
+// resource identified in the patch itself.
+func (k *Kustomization) AddPatch(path string, target *PatchTarget) *Kustomization {
+	patch := map[string]interface{}{"path": path}
+	if target != nil {
+		patch["target"] = target.toMap()
+	}
+
+	k.data["patches"] = append(k.list("patches"), patch)
+	return k
+}
+
+// AddJSON6902Patch adds an inline JSON6902 patch, written as YAML, applied to the target
+func (k *Kustomization) AddJSON6902Patch(target PatchTarget, patch string) *Kustomization {
+	k.data["patchesJson6902"] = append(k.list("patchesJson6902"), map[string]interface{}{
+		"target": target.toMap(),
+		"patch":  patch,
+	})
+	return k
+}
+
+// SetImage sets the replacement for the image name, replacing any existing replacement
+// for it. Empty values are omitted, i.e to only change the tag.
+func (k *Kustomization) SetImage(name string, newName string, newTag string) *Kustomization {
+	image := map[string]interface{}{"name": name}
+	if newName != "" {
+		image["newName"] = newName
+	}
+	if newTag != "" {
+		image["newTag"] = newTag
+	}
+
+	images := []interface{}{}
+	for _, i := range k.list("images") {
+		if m, ok := i.(map[string]interface{}); ok && m["name"] == name {
+			continue
+		}
+		images = append(images, i)
+	}
+
+	k.data["images"] = append(images, image)
+	return k
+}
+
+// EnableWebhook enables the webhook overlay and the manager patch that serves the
+// webhooks, the equivalent of uncommenting the [WEBHOOK] sections
+func (k *Kustomization) EnableWebhook() *Kustomization {
+	return k.AddResources("../webhook").AddPatchesStrategicMerge("manager_webhook_patch.yaml")
+}
+
+// EnableCertManager enables the certmanager overlay, the CA injection patch and the vars
+// they use, the equivalent of uncommenting the [CERTMANAGER] sections. It requires the
+// webhook to be enabled.
+func (k *Kustomization) EnableCertManager() *Kustomization {
+	k.AddResources("../certmanager").AddPatchesStrategicMerge("webhookcainjection_patch.yaml")
+
+	vars := k.list("vars")
+	for _, v := range certManagerVars() {
+		if !containsVar(vars, v["name"]) {
+			vars = append(vars, v)
+		}
+	}
+	k.data["vars"] = vars
+
+	return k
+}
+
+// EnablePrometheus enables the prometheus overlay that creates a ServiceMonitor
+// for the manager, the equivalent of uncommenting the [PROMETHEUS] section
+func (k *Kustomization) EnablePrometheus() *Kustomization {
+	return k.AddResources("../prometheus")
+}
+
+// YAML returns the kustomization encoded as YAML
+func (k *Kustomization) YAML() ([]byte, error) {
+	b, err := yaml.Marshal(k.data)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when encoding the kustomization: %w", err)
+	}
+
+	return b, nil
+}
+
+// Save writes the kustomization back to its kustomization.yaml
+func (k *Kustomization) Save() error {
+	b, err := k.YAML()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(k.Path(), b, 0644); err != nil {
+		return fmt.Errorf("encountered an error when writing %q: %w", k.Path(), err)
+	}
+
+	return nil
+}
+
+// Build renders the saved kustomization with `kustomize build`, using the kustomize
+// binary the scaffolded Makefile installs to bin/kustomize
+func (k *Kustomization) Build() ([]byte, error) {
+	return BuildKustomization(k.sample, k.dir)
+}
+
+// BuildKustomization renders the kustomize directory of the Sample with `kustomize build`,
+// using the kustomize binary the scaffolded Makefile installs to bin/kustomize
+func BuildKustomization(sample Sample, dir string) ([]byte, error) {
+	cmd := exec.Command("make", "kustomize")
+	out, err := sample.CommandContext().Run(cmd, sample.Name())
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when installing kustomize: %w, output: %s", err, string(out))
+	}
+
+	root, err := filepath.Abs(sampleDir(sample))
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when resolving the sample directory: %w", err)
+	}
+
+	// Only stdout is the rendered output, kustomize writes warnings to stderr
+	var stderr bytes.Buffer
+	cmd = exec.Command(filepath.Join(root, "bin", "kustomize"), "build", dir)
+	cmd.Dir = root
+	cmd.Env = append(os.Environ(), sample.CommandContext().Env()...)
+	cmd.Stderr = &stderr
+
+	out, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when building %s: %w, output: %s", dir, err, stderr.String())
+	}
+
+	return out, nil
+}
+
+// list returns the field as a list, or an empty list if it is not set,
+// i.e when only commented out entries were scaffolded
+func (k *Kustomization) list(field string) []interface{} {
+	l, _ := k.data[field].([]interface{})
+	return l
+}
+
+func (k *Kustomization) appendUnique(field string, values ...string) {
+	l := k.list(field)
+	for _, value := range values {
+		found := false
+		for _, existing := range l {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			l = append(l, value)
+		}
+	}
+	k.data[field] = l
+}
+
+func (k *Kustomization) remove(field string, values ...string) {
+	l, ok := k.data[field].([]interface{})
+	if !ok {
+		return
+	}
+
+	kept := []interface{}{}
+	for _, existing := range l {
+		removed := false
+		for _, value := range values {
+			if existing == value {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, existing)
+		}
+	}
+	k.data[field] = kept
+}
+
+func (pt PatchTarget) toMap() map[string]interface{} {
+	m := map[string]interface{}{}
+	for key, value := range map[string]string{
+		"group":     pt.Group,
+		"version":   pt.Version,
+		"kind":      pt.Kind,
+		"name":      pt.Name,
+		"namespace": pt.Namespace,
+	} {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+func kustomizeVar(name, kind, group, version, objName, fieldPath string) map[string]interface{} {
+	objref := map[string]interface{}{
+		"kind":    kind,
+		"version": version,
+		"name":    objName,
+	}
+	if group != "" {
+		objref["group"] = group
+	}
+
+	v := map[string]interface{}{
+		"name":   name,
+		"objref": objref,
+	}
+	if fieldPath != "" {
+		v["fieldref"] = map[string]interface{}{"fieldpath": fieldPath}
+	}
+	return v
+}
+
+func containsVar(vars []interface{}, name interface{}) bool {
+	for _, v := range vars {
+		if m, ok := v.(map[string]interface{}); ok && m["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleDir returns the directory the Sample is scaffolded in
+func sampleDir(sample Sample) string {
+	return filepath.Join(sample.CommandContext().Dir(), sample.Name())
+}
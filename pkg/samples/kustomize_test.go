@@ -0,0 +1,195 @@
+package samples
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/command"
+	"sigs.k8s.io/yaml"
+)
+
+const scaffoldedKustomization = `# Adds namespace to all resources.
+namespace: memcached-operator-system
+namePrefix: memcached-operator-
+
+bases:
+- ../crd
+- ../rbac
+- ../manager
+# [WEBHOOK] To enable webhook, uncomment all the sections with [WEBHOOK] prefix including the one in
+# crd/kustomization.yaml
+#- ../webhook
+# [CERTMANAGER] To enable cert-manager, uncomment all sections with 'CERTMANAGER'. 'WEBHOOK' components are required.
+#- ../certmanager
+
+patchesStrategicMerge:
+- manager_auth_proxy_patch.yaml
+
+#- manager_webhook_patch.yaml
+`
+
+func TestKustomizationEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		edit     func(k *Kustomization) *Kustomization
+		expected string
+	}{
+		{
+			name:     "resources are added to bases when scaffolded",
+			existing: scaffoldedKustomization,
+			edit: func(k *Kustomization) *Kustomization {
+				return k.AddResources("../prometheus", "../crd")
+			},
+			expected: `
+namespace: memcached-operator-system
+namePrefix: memcached-operator-
+bases: [../crd, ../rbac, ../manager, ../prometheus]
+patchesStrategicMerge: [manager_auth_proxy_patch.yaml]
+`,
+		},
+		{
+			name:     "resources are added to resources without bases",
+			existing: "resources:\n- manager.yaml\n",
+			edit: func(k *Kustomization) *Kustomization {
+				return k.AddResources("service.yaml")
+			},
+			expected: "resources: [manager.yaml, service.yaml]",
+		},
+		{
+			name:     "resources are removed from bases and resources",
+			existing: "bases: [../crd, ../rbac]\nresources: [../rbac, extra.yaml]\n",
+			edit: func(k *Kustomization) *Kustomization {
+				return k.RemoveResources("../rbac")
+			},
+			expected: "bases: [../crd]\nresources: [extra.yaml]",
+		},
+		{
+			name:     "webhook and cert-manager are enabled once",
+			existing: scaffoldedKustomization,
+			edit: func(k *Kustomization) *Kustomization {
+				return k.EnableWebhook().EnableCertManager().EnableWebhook().EnableCertManager()
+			},
+			expected: `
+namespace: memcached-operator-system
+namePrefix: memcached-operator-
+bases: [../crd, ../rbac, ../manager, ../webhook, ../certmanager]
+patchesStrategicMerge: [manager_auth_proxy_patch.yaml, manager_webhook_patch.yaml, webhookcainjection_patch.yaml]
+vars:
+- name: CERTIFICATE_NAMESPACE
+  objref: {kind: Certificate, group: cert-manager.io, version: v1, name: serving-cert}
+  fieldref: {fieldpath: metadata.namespace}
+- name: CERTIFICATE_NAME
+  objref: {kind: Certificate, group: cert-manager.io, version: v1, name: serving-cert}
+- name: SERVICE_NAMESPACE
+  objref: {kind: Service, version: v1, name: webhook-service}
+  fieldref: {fieldpath: metadata.namespace}
+- name: SERVICE_NAME
+  objref: {kind: Service, version: v1, name: webhook-service}
+`,
+		},
+		{
+			name:     "image replacement is replaced",
+			existing: "images:\n- name: controller\n  newName: controller\n  newTag: latest\n- name: other\n",
+			edit: func(k *Kustomization) *Kustomization {
+				return k.SetImage("controller", "", "v0.0.2")
+			},
+			expected: "images:\n- name: other\n- name: controller\n  newTag: v0.0.2\n",
+		},
+		{
+			name:     "patches keep only set target fields",
+			existing: "",
+			edit: func(k *Kustomization) *Kustomization {
+				return k.
+					AddPatch("patch.yaml", &PatchTarget{Kind: "Deployment", Name: "controller-manager"}).
+					AddPatch("untargeted.yaml", nil).
+					AddJSON6902Patch(PatchTarget{Group: "apps", Version: "v1", Kind: "Deployment", Name: "controller-manager"}, "- op: remove\n  path: /spec/replicas\n")
+			},
+			expected: `
+patches:
+- path: patch.yaml
+  target: {kind: Deployment, name: controller-manager}
+- path: untargeted.yaml
+patchesJson6902:
+- target: {group: apps, version: v1, kind: Deployment, name: controller-manager}
+  patch: "- op: remove\n  path: /spec/replicas\n"
+`,
+		},
+		{
+			name:     "namespace and name prefix are replaced",
+			existing: scaffoldedKustomization,
+			edit: func(k *Kustomization) *Kustomization {
+				return k.SetNamespace("e2e-abcde").SetNamePrefix("")
+			},
+			expected: `
+namespace: e2e-abcde
+namePrefix: ""
+bases: [../crd, ../rbac, ../manager]
+patchesStrategicMerge: [manager_auth_proxy_patch.yaml]
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample := newKustomizeTestSample(t, tt.existing)
+
+			k, err := Kustomize(sample, DefaultKustomizeDir)
+			if err != nil {
+				t.Fatalf("unexpected error loading the kustomization: %v", err)
+			}
+			if err := tt.edit(k).Save(); err != nil {
+				t.Fatalf("unexpected error saving the kustomization: %v", err)
+			}
+
+			saved, err := Kustomize(sample, DefaultKustomizeDir)
+			if err != nil {
+				t.Fatalf("unexpected error reloading the kustomization: %v", err)
+			}
+
+			var expected map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatalf("invalid expected kustomization: %v", err)
+			}
+			if !reflect.DeepEqual(saved.Data(), expected) {
+				got, _ := saved.YAML()
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKustomizeMissingFile(t *testing.T) {
+	sample := NewGenericSample(
+		WithName("memcached-operator"),
+		WithCommandContext(command.NewGenericCommandContext(command.WithDir(t.TempDir()))),
+	)
+
+	if _, err := Kustomize(sample, DefaultKustomizeDir); err == nil {
+		t.Errorf("expected an error for a missing kustomization.yaml")
+	}
+}
+
+// newKustomizeTestSample returns a Sample in a temporary directory with
+// config/default/kustomization.yaml set to the given content
+func newKustomizeTestSample(t *testing.T, kustomization string) Sample {
+	t.Helper()
+
+	dir := t.TempDir()
+	sample := NewGenericSample(
+		WithName("memcached-operator"),
+		WithCommandContext(command.NewGenericCommandContext(command.WithDir(dir))),
+	)
+
+	path := filepath.Join(dir, sample.Name(), DefaultKustomizeDir, "kustomization.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(kustomization), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return sample
+}
@@ -0,0 +1,80 @@
+package memcached
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+)
+
+func implementAnsible(ms *MemcachedSample) error {
+	roleDir := filepath.Join(ms.dir(), "roles", strings.ToLower(ms.GVK().Kind))
+
+	files := map[string]string{
+		filepath.Join(roleDir, "defaults", "main.yml"): fmt.Sprintf(ansibleDefaultsFragment, ms.size),
+		filepath.Join(roleDir, "tasks", "main.yml"):    fmt.Sprintf(ansibleTasksFragment, OperandImage),
+	}
+	if err := writeFiles(files); err != nil {
+		return err
+	}
+
+	if err := ms.addManagerRule(deploymentsRule); err != nil {
+		return err
+	}
+
+	return ms.setSampleSize()
+}
+
+// expectAnsible expects the role to have run successfully and its Deployment to be owned
+// by the custom resource
+func expectAnsible(ms *MemcachedSample) []e2e.ReconcileOption {
+	return []e2e.ReconcileOption{
+		e2e.WithCondition("Successful", "True"),
+		e2e.WithOwnedResources("deployments.apps"),
+		ms.sizeExpectation(),
+	}
+}
+
+const ansibleDefaultsFragment = `---
+# defaults file for Memcached
+size: %d
+`
+
+const ansibleTasksFragment = `---
+- name: start memcached
+  kubernetes.core.k8s:
+    definition:
+      kind: Deployment
+      apiVersion: apps/v1
+      metadata:
+        name: '{{ ansible_operator_meta.name }}-memcached'
+        namespace: '{{ ansible_operator_meta.namespace }}'
+        labels:
+          app: memcached
+          memcached_cr: '{{ ansible_operator_meta.name }}'
+      spec:
+        replicas: "{{size}}"
+        selector:
+          matchLabels:
+            app: memcached
+            memcached_cr: '{{ ansible_operator_meta.name }}'
+        template:
+          metadata:
+            labels:
+              app: memcached
+              memcached_cr: '{{ ansible_operator_meta.name }}'
+          spec:
+            containers:
+            - name: memcached
+              command:
+              - memcached
+              - -m=64
+              - -o
+              - modern
+              - -v
+              image: "%s"
+              ports:
+                - containerPort: 11211
+                  name: memcached
+`
@@ -0,0 +1,419 @@
+package memcached
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+	"github.com/everettraven/plugin-testing-poc/pkg/mutate"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func implementGo(ms *MemcachedSample) error {
+	if err := implementGoApi(ms.dir(), ms.GVK()); err != nil {
+		return fmt.Errorf("encountered an error implementing the api: %w", err)
+	}
+
+	if err := implementGoController(ms.dir(), ms.GVK()); err != nil {
+		return fmt.Errorf("encountered an error implementing the controller: %w", err)
+	}
+
+	if ms.webhook {
+		if err := implementGoWebhook(ms.dir(), ms.GVK(), ms.domain); err != nil {
+			return fmt.Errorf("encountered an error implementing the webhook: %w", err)
+		}
+
+		if err := enableWebhookKustomization(ms); err != nil {
+			return fmt.Errorf("encountered an error enabling the webhook kustomization: %w", err)
+		}
+
+		if err := removeWebhookCertsFromManifests(ms); err != nil {
+			return fmt.Errorf("encountered an error updating the manifests kustomization: %w", err)
+		}
+	}
+
+	if err := ms.setSampleSize(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "mod", "tidy")
+	if out, err := ms.CommandContext().Run(cmd, ms.Name()); err != nil {
+		return fmt.Errorf("encountered an error running go mod tidy: %w, output: %s", err, string(out))
+	}
+
+	return nil
+}
+
+// expectGo expects the controller to own a Deployment for the custom resource and
+// to have observed its latest generation
+func expectGo(ms *MemcachedSample) []e2e.ReconcileOption {
+	return []e2e.ReconcileOption{
+		e2e.WithOwnedResources("deployments.apps"),
+		e2e.WithObservedGeneration(),
+		ms.sizeExpectation(),
+	}
+}
+
+func implementGoApi(dir string, gvk schema.GroupVersionKind) error {
+	typesFile, err := mutate.LoadGoFile(filepath.Join(dir, "api", gvk.Version, fmt.Sprintf("%s_types.go", strings.ToLower(gvk.Kind))))
+	if err != nil {
+		return err
+	}
+
+	err = typesFile.AddStructField(gvk.Kind+"Spec", mutate.StructField{
+		Name:    "Size",
+		Type:    "int32",
+		Tag:     `json:"size,omitempty"`,
+		Doc:     []string{"Size defines the number of Memcached instances"},
+		Markers: []string{"+operator-sdk:csv:customresourcedefinitions:type=spec"},
+	})
+	if err != nil {
+		return fmt.Errorf("encountered an error inserting spec Size: %w", err)
+	}
+
+	err = typesFile.AddStructField(gvk.Kind+"Status", mutate.StructField{
+		Name:    "Nodes",
+		Type:    "[]string",
+		Tag:     `json:"nodes,omitempty"`,
+		Doc:     []string{"Nodes store the name of the pods which are running Memcached instances"},
+		Markers: []string{"+operator-sdk:csv:customresourcedefinitions:type=status"},
+	})
+	if err != nil {
+		return fmt.Errorf("encountered an error inserting status Nodes: %w", err)
+	}
+
+	err = typesFile.AddStructField(gvk.Kind+"Status", mutate.StructField{
+		Name:    "ObservedGeneration",
+		Type:    "int64",
+		Tag:     `json:"observedGeneration,omitempty"`,
+		Doc:     []string{"ObservedGeneration is the generation of the spec that the status reflects"},
+		Markers: []string{"+operator-sdk:csv:customresourcedefinitions:type=status"},
+	})
+	if err != nil {
+		return fmt.Errorf("encountered an error inserting status ObservedGeneration: %w", err)
+	}
+
+	// Add CSV marker that shows CRD owned resources
+	err = typesFile.AddTypeMarkers(gvk.Kind, "+operator-sdk:csv:customresourcedefinitions:resources={{Deployment,v1,memcached-deployment}}")
+	if err != nil {
+		return fmt.Errorf("encountered an error inserting the owned resources marker: %w", err)
+	}
+
+	return typesFile.Save()
+}
+
+func implementGoController(dir string, gvk schema.GroupVersionKind) error {
+	controller, err := mutate.LoadGoFile(filepath.Join(dir, "controllers", fmt.Sprintf("%s_controller.go",
+		strings.ToLower(gvk.Kind))))
+	if err != nil {
+		return err
+	}
+	reconcilerName := gvk.Kind + "Reconciler"
+
+	// Add imports
+	for _, imp := range controllerImports {
+		if err := controller.AddImport(imp[0], imp[1]); err != nil {
+			return fmt.Errorf("encountered an error adding imports: %w", err)
+		}
+	}
+	// The reconcile implementation uses log as the logger variable
+	err = controller.SetImportName("sigs.k8s.io/controller-runtime/pkg/log", "ctrllog")
+	if err != nil {
+		return fmt.Errorf("encountered an error replacing controller log import: %w", err)
+	}
+
+	// Add RBAC permissions on top of reconcile
+	err = controller.AddRBACMarkers(reconcilerName+".Reconcile", controllerRBAC...)
+	if err != nil {
+		return fmt.Errorf("encountered an error adding rbac: %w", err)
+	}
+
+	// Add reconcile implementation
+	err = controller.SetFunctionBody(reconcilerName+".Reconcile", reconcileFragment)
+	if err != nil {
+		return fmt.Errorf("encountered an error replacing reconcile content: %w", err)
+	}
+
+	// Add helpers funcs to the controller
+	err = controller.AddDecls(controllerFuncsFragment)
+	if err != nil {
+		return fmt.Errorf("encountered an error adding helper methods in the controller: %w", err)
+	}
+
+	// Add watch for the Kind
+	err = controller.SetFunctionBody(reconcilerName+".SetupWithManager",
+		fmt.Sprintf(watchCustomizedFragment, gvk.Group, gvk.Version, gvk.Kind))
+	if err != nil {
+		return fmt.Errorf("encountered an error replacing add controller to manager: %w", err)
+	}
+
+	return controller.Save()
+}
+
+func implementGoWebhook(dir string, gvk schema.GroupVersionKind, domain string) error {
+	webhook, err := mutate.LoadGoFile(filepath.Join(dir, "api", gvk.Version, fmt.Sprintf("%s_webhook.go",
+		strings.ToLower(gvk.Kind))))
+	if err != nil {
+		return err
+	}
+
+	// Add imports
+	// TODO(estroz): remove runtime dep when --programmatic-validation is added to `ccreate webhook` above.
+	for _, path := range []string{"errors", "k8s.io/apimachinery/pkg/runtime"} {
+		if err := webhook.AddImport("", path); err != nil {
+			return fmt.Errorf("encountered an error adding imports: %w", err)
+		}
+	}
+
+	err = webhook.SetFunctionBody(gvk.Kind+".Default", defaultFragment)
+	if err != nil {
+		return fmt.Errorf("encountered an error replacing webhook default implementation: %w", err)
+	}
+
+	// Add webhook methods
+	err = webhook.AddDecls(fmt.Sprintf(webhooksFragment,
+		gvk.Group, strings.ReplaceAll(domain, ".", "-"), gvk.Version, gvk.Group, domain, gvk.Version))
+	if err != nil {
+		return fmt.Errorf("encountered an error adding webhook validate implementation: %w", err)
+	}
+
+	return webhook.Save()
+}
+
+func enableWebhookKustomization(sample samples.Sample) error {
+	kustomization, err := samples.Kustomize(sample, samples.DefaultKustomizeDir)
+	if err != nil {
+		return err
+	}
+
+	err = kustomization.
+		EnableWebhook().
+		EnableCertManager().
+		EnablePrometheus().
+		Save()
+	if err != nil {
+		return fmt.Errorf("encountered an error enabling the webhook in the default kustomization: %w", err)
+	}
+
+	return nil
+}
+
+func removeWebhookCertsFromManifests(sample samples.Sample) error {
+	kustomization, err := samples.Kustomize(sample, samples.ManifestsKustomizeDir)
+	if err != nil {
+		return err
+	}
+
+	// OLM creates and mounts its own set of certs, so remove the manager container's
+	// "cert" volumeMount and volume. Update the indices in these paths if adding or
+	// removing containers, volumeMounts or volumes in the manager's Deployment.
+	err = kustomization.AddJSON6902Patch(samples.PatchTarget{
+		Group:     "apps",
+		Version:   "v1",
+		Kind:      "Deployment",
+		Name:      "controller-manager",
+		Namespace: "system",
+	}, `- op: remove
+  path: /spec/template/spec/containers/1/volumeMounts/0
+- op: remove
+  path: /spec/template/spec/volumes/0`).Save()
+	if err != nil {
+		return fmt.Errorf("encountered an error adding the webhook volume removal patch: %w", err)
+	}
+
+	return nil
+}
+
+var controllerRBAC = []mutate.RBACRule{
+	{
+		Groups:    []string{"apps"},
+		Resources: []string{"deployments"},
+		Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+	},
+	{
+		Groups:    []string{"core"},
+		Resources: []string{"pods"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+}
+
+const reconcileFragment = `log := ctrllog.FromContext(ctx)
+
+	// Fetch the Memcached instance
+	memcached := &cachev1alpha1.Memcached{}
+	err := r.Get(ctx, req.NamespacedName, memcached)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// Return and don't requeue
+			log.Info("Memcached resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		log.Error(err, "Failed to get Memcached")
+		return ctrl.Result{}, err
+	}
+	// Check if the deployment already exists, if not create a new one
+	found := &appsv1.Deployment{}
+	err = r.Get(ctx, types.NamespacedName{Name: memcached.Name, Namespace: memcached.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		// Define a new deployment
+		dep := r.deploymentForMemcached(memcached)
+		log.Info("Creating a new Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		err = r.Create(ctx, dep)
+		if err != nil {
+			log.Error(err, "Failed to create new Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+			return ctrl.Result{}, err
+		}
+		// Deployment created successfully - return and requeue
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Deployment")
+		return ctrl.Result{}, err
+	}
+	// Ensure the deployment size is the same as the spec
+	size := memcached.Spec.Size
+	if *found.Spec.Replicas != size {
+		found.Spec.Replicas = &size
+		err = r.Update(ctx, found)
+		if err != nil {
+			log.Error(err, "Failed to update Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+		// Ask to requeue after 1 minute in order to give enough time for the
+		// pods be created on the cluster side and the operand be able
+		// to do the next update step accurately.
+		return ctrl.Result{RequeueAfter: time.Minute }, nil
+	}
+	// Update the Memcached status with the pod names
+	// List the pods for this memcached's deployment
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(memcached.Namespace),
+		client.MatchingLabels(labelsForMemcached(memcached.Name)),
+	}
+	if err = r.List(ctx, podList, listOpts...); err != nil {
+		log.Error(err, "Failed to list pods", "Memcached.Namespace", memcached.Namespace, "Memcached.Name", memcached.Name)
+		return ctrl.Result{}, err
+	}
+	podNames := getPodNames(podList.Items)
+	// Update status.Nodes and status.ObservedGeneration if needed
+	if !reflect.DeepEqual(podNames, memcached.Status.Nodes) || memcached.Status.ObservedGeneration != memcached.Generation {
+		memcached.Status.Nodes = podNames
+		memcached.Status.ObservedGeneration = memcached.Generation
+		err := r.Status().Update(ctx, memcached)
+		if err != nil {
+			log.Error(err, "Failed to update Memcached status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+`
+
+const controllerFuncsFragment = `
+// deploymentForMemcached returns a memcached Deployment object
+func (r *MemcachedReconciler) deploymentForMemcached(m *cachev1alpha1.Memcached) *appsv1.Deployment {
+	ls := labelsForMemcached(m.Name)
+	replicas := m.Spec.Size
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name,
+			Namespace: m.Namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: ls,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image:   "memcached:1.4.36-alpine",
+						Name:    "memcached",
+						Command: []string{"memcached", "-m=64", "-o", "modern", "-v"},
+						Ports: []corev1.ContainerPort{{
+							ContainerPort: 11211,
+							Name:          "memcached",
+						}},
+					}},
+				},
+			},
+		},
+	}
+	// Set Memcached instance as the owner and controller
+	ctrl.SetControllerReference(m, dep, r.Scheme)
+	return dep
+}
+// labelsForMemcached returns the labels for selecting the resources
+// belonging to the given memcached CR name.
+func labelsForMemcached(name string) map[string]string {
+	return map[string]string{"app": "memcached", "memcached_cr": name}
+}
+// getPodNames returns the pod names of the array of pods passed in
+func getPodNames(pods []corev1.Pod) []string {
+	var podNames []string
+	for _, pod := range pods {
+		podNames = append(podNames, pod.Name)
+	}
+	return podNames
+}
+`
+
+// controllerImports are the name and path of the imports the reconcile implementation uses
+var controllerImports = [][2]string{
+	{"", "reflect"},
+	{"", "time"},
+	{"appsv1", "k8s.io/api/apps/v1"},
+	{"corev1", "k8s.io/api/core/v1"},
+	{"", "k8s.io/apimachinery/pkg/api/errors"},
+	{"metav1", "k8s.io/apimachinery/pkg/apis/meta/v1"},
+	{"", "k8s.io/apimachinery/pkg/types"},
+}
+
+const watchCustomizedFragment = `return ctrl.NewControllerManagedBy(mgr).
+		For(&%s%s.%s{}).
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
+`
+
+const defaultFragment = `memcachedlog.Info("default", "name", r.Name)
+
+	if r.Spec.Size == 0 {
+		r.Spec.Size = 3
+	}
+`
+
+const webhooksFragment = `
+// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
+//+kubebuilder:webhook:path=/validate-%s-%s-%s-memcached,mutating=false,failurePolicy=fail,sideEffects=None,groups=%s.%s,resources=memcacheds,verbs=create;update,versions=%s,name=vmemcached.kb.io,admissionReviewVersions=v1
+var _ webhook.Validator = &Memcached{}
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Memcached) ValidateCreate() error {
+	memcachedlog.Info("validate create", "name", r.Name)
+	return validateOdd(r.Spec.Size)
+}
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Memcached) ValidateUpdate(old runtime.Object) error {
+	memcachedlog.Info("validate update", "name", r.Name)
+	return validateOdd(r.Spec.Size)
+}
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Memcached) ValidateDelete() error {
+	memcachedlog.Info("validate delete", "name", r.Name)
+	return nil
+}
+func validateOdd(n int32) error {
+	if n%%2 == 0 {
+		return errors.New("Cluster size must be an odd number")
+	}
+	return nil
+}
+`
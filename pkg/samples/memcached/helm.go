@@ -0,0 +1,79 @@
+package memcached
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+)
+
+func implementHelm(ms *MemcachedSample) error {
+	chartDir := filepath.Join(ms.dir(), "helm-charts", strings.ToLower(ms.GVK().Kind))
+
+	// Replace the default chart scaffolded by `create api` with one that only deploys memcached
+	if err := os.RemoveAll(filepath.Join(chartDir, "templates")); err != nil {
+		return fmt.Errorf("encountered an error removing the scaffolded chart templates: %w", err)
+	}
+
+	files := map[string]string{
+		filepath.Join(chartDir, "values.yaml"):                  fmt.Sprintf(helmValuesFragment, ms.size),
+		filepath.Join(chartDir, "templates", "deployment.yaml"): fmt.Sprintf(helmDeploymentFragment, OperandImage),
+	}
+	if err := writeFiles(files); err != nil {
+		return err
+	}
+
+	if err := ms.addManagerRule(deploymentsRule); err != nil {
+		return err
+	}
+
+	return ms.setSampleSize()
+}
+
+// expectHelm expects the chart to be installed and its Deployment owned by the custom resource
+func expectHelm(ms *MemcachedSample) []e2e.ReconcileOption {
+	return []e2e.ReconcileOption{
+		e2e.WithCondition("Deployed", "True"),
+		e2e.WithOwnedResources("deployments.apps"),
+		ms.sizeExpectation(),
+	}
+}
+
+const helmValuesFragment = `# size is the number of memcached pods
+size: %d
+`
+
+const helmDeploymentFragment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}-memcached
+  labels:
+    app: memcached
+    memcached_cr: {{ .Release.Name }}
+spec:
+  replicas: {{ .Values.size }}
+  selector:
+    matchLabels:
+      app: memcached
+      memcached_cr: {{ .Release.Name }}
+  template:
+    metadata:
+      labels:
+        app: memcached
+        memcached_cr: {{ .Release.Name }}
+    spec:
+      containers:
+      - name: memcached
+        image: %s
+        command:
+        - memcached
+        - -m=64
+        - -o
+        - modern
+        - -v
+        ports:
+        - containerPort: 11211
+          name: memcached
+`
@@ -0,0 +1,284 @@
+// Package memcached provides the Memcached operator used throughout the operator-sdk
+// tutorials as a reusable sample, implemented for the Go, Helm and Ansible plugins so
+// every plugin can be tested against the same operator
+package memcached
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"github.com/everettraven/plugin-testing-poc/pkg/command"
+	"github.com/everettraven/plugin-testing-poc/pkg/e2e"
+	"github.com/everettraven/plugin-testing-poc/pkg/generator"
+	"github.com/everettraven/plugin-testing-poc/pkg/mutate"
+	"github.com/everettraven/plugin-testing-poc/pkg/samples"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// OperandImage is the memcached image the operator deploys
+	OperandImage = "memcached:1.4.36-alpine"
+
+	goPlugin      = "go/v3"
+	helmPlugin    = "helm.sdk.operatorframework.io/v1"
+	ansiblePlugin = "ansible.sdk.operatorframework.io/v1"
+)
+
+// GVK is the GroupVersionKind of the Memcached API
+var GVK = schema.GroupVersionKind{
+	Group:   "cache",
+	Version: "v1alpha1",
+	Kind:    "Memcached",
+}
+
+// MemcachedSample is a Sample that scaffolds the Memcached operator and implements it
+type MemcachedSample struct {
+	*samples.GenericSample
+
+	plugin    string
+	domain    string
+	size      int32
+	webhook   bool
+	implement func(ms *MemcachedSample) error
+	expect    func(ms *MemcachedSample) []e2e.ReconcileOption
+}
+
+// MemcachedSampleOptions configures a MemcachedSample
+type MemcachedSampleOptions struct {
+	dir     string
+	name    string
+	binary  string
+	domain  string
+	size    int32
+	webhook bool
+}
+
+type MemcachedSampleOption func(mso *MemcachedSampleOptions)
+
+// WithDir sets the directory the sample is scaffolded in
+func WithDir(dir string) MemcachedSampleOption {
+	return func(mso *MemcachedSampleOptions) {
+		mso.dir = dir
+	}
+}
+
+// WithName sets the name of the sample. Defaults to memcached-operator.
+func WithName(name string) MemcachedSampleOption {
+	return func(mso *MemcachedSampleOptions) {
+		mso.name = name
+	}
+}
+
+// WithBinary sets the operator-sdk binary used to scaffold the sample
+func WithBinary(binary string) MemcachedSampleOption {
+	return func(mso *MemcachedSampleOptions) {
+		mso.binary = binary
+	}
+}
+
+// WithDomain sets the domain of the Memcached API. Defaults to example.com.
+func WithDomain(domain string) MemcachedSampleOption {
+	return func(mso *MemcachedSampleOptions) {
+		mso.domain = domain
+	}
+}
+
+// WithSize sets spec.size of the sample custom resource. Defaults to 1.
+func WithSize(size int32) MemcachedSampleOption {
+	return func(mso *MemcachedSampleOptions) {
+		mso.size = size
+	}
+}
+
+// WithWebhook scaffolds and implements the defaulting and validating webhooks, and enables
+// them in the kustomizations. Only the Go sample has webhooks, the option is ignored otherwise.
+func WithWebhook(webhook bool) MemcachedSampleOption {
+	return func(mso *MemcachedSampleOptions) {
+		mso.webhook = webhook
+	}
+}
+
+// NewMemcachedSample creates the Memcached sample for the Go plugin. The controller
+// creates a Deployment of spec.size memcached pods and lists their names in status.nodes.
+func NewMemcachedSample(opts ...MemcachedSampleOption) *MemcachedSample {
+	ms := newMemcachedSample(goPlugin, opts,
+		samples.WithExtraApiOptions("--resource", "--controller"),
+		samples.WithExtraWebhookOptions("--defaulting"),
+	)
+	ms.implement = implementGo
+	ms.expect = expectGo
+
+	return ms
+}
+
+// NewHelmMemcachedSample creates the Memcached sample for the Helm plugin. The chart
+// creates a Deployment of .Values.size memcached pods.
+func NewHelmMemcachedSample(opts ...MemcachedSampleOption) *MemcachedSample {
+	ms := newMemcachedSample(helmPlugin, opts)
+	ms.webhook = false
+	ms.implement = implementHelm
+	ms.expect = expectHelm
+
+	return ms
+}
+
+// NewAnsibleMemcachedSample creates the Memcached sample for the Ansible plugin. The role
+// creates a Deployment of size memcached pods.
+func NewAnsibleMemcachedSample(opts ...MemcachedSampleOption) *MemcachedSample {
+	ms := newMemcachedSample(ansiblePlugin, opts,
+		samples.WithExtraApiOptions("--generate-role", "--generate-playbook"),
+	)
+	ms.webhook = false
+	ms.implement = implementAnsible
+	ms.expect = expectAnsible
+
+	return ms
+}
+
+func newMemcachedSample(plugin string, opts []MemcachedSampleOption, sampleOpts ...samples.GenericSampleOption) *MemcachedSample {
+	mso := &MemcachedSampleOptions{
+		name:   "memcached-operator",
+		binary: "operator-sdk",
+		domain: "example.com",
+		size:   1,
+	}
+
+	for _, opt := range opts {
+		opt(mso)
+	}
+
+	sampleOpts = append([]samples.GenericSampleOption{
+		samples.WithBinary(mso.binary),
+		samples.WithDomain(mso.domain),
+		samples.WithGvk(GVK),
+		samples.WithName(mso.name),
+		samples.WithPlugins(plugin),
+		samples.WithCommandContext(
+			command.NewGenericCommandContext(
+				command.WithDir(mso.dir),
+			),
+		),
+	}, sampleOpts...)
+
+	return &MemcachedSample{
+		GenericSample: samples.NewGenericSample(sampleOpts...),
+		plugin:        plugin,
+		domain:        mso.domain,
+		size:          mso.size,
+		webhook:       mso.webhook,
+	}
+}
+
+// Plugin returns the plugin the sample is scaffolded with
+func (ms *MemcachedSample) Plugin() string {
+	return ms.plugin
+}
+
+// Size returns spec.size of the sample custom resource
+func (ms *MemcachedSample) Size() int32 {
+	return ms.size
+}
+
+// Generate scaffolds the sample and implements the Memcached operator in it
+func (ms *MemcachedSample) Generate() error {
+	genOpts := []generator.GenericGeneratorOptions{}
+	if !ms.webhook {
+		genOpts = append(genOpts, generator.WithNoWebhook())
+	}
+
+	if err := generator.NewGenericGenerator(genOpts...).GenerateSamples(ms); err != nil {
+		return fmt.Errorf("encountered an error when scaffolding the sample: %w", err)
+	}
+
+	return ms.Implement()
+}
+
+// Implement implements the Memcached operator in an already scaffolded sample
+func (ms *MemcachedSample) Implement() error {
+	if err := ms.implement(ms); err != nil {
+		return fmt.Errorf("encountered an error when implementing the %s memcached sample: %w", ms.plugin, err)
+	}
+
+	return nil
+}
+
+// ReconcileOptions returns the expectations on a reconciled sample custom resource,
+// to be passed to e2e.ExpectReconciled
+func (ms *MemcachedSample) ReconcileOptions() []e2e.ReconcileOption {
+	return ms.expect(ms)
+}
+
+// dir returns the directory the sample is scaffolded in
+func (ms *MemcachedSample) dir() string {
+	return filepath.Join(ms.CommandContext().Dir(), ms.Name())
+}
+
+// setSampleSize sets spec.size in the scaffolded custom resource sample, replacing any
+// other fields in spec
+func (ms *MemcachedSample) setSampleSize() error {
+	sampleFile, err := mutate.LoadYAMLFile(samples.CustomResourceSamplePath(ms))
+	if err != nil {
+		return err
+	}
+
+	if err := sampleFile.Set(map[string]interface{}{"size": int64(ms.size)}, "spec"); err != nil {
+		return fmt.Errorf("encountered an error updating the custom resource sample: %w", err)
+	}
+
+	return sampleFile.Save()
+}
+
+// sizeExpectation expects the custom resource to have been created with the configured size
+func (ms *MemcachedSample) sizeExpectation() e2e.ReconcileOption {
+	return e2e.WithJSONPath("{.spec.size}", strconv.Itoa(int(ms.size)))
+}
+
+// deploymentsRule allows the manager to manage the memcached Deployment
+var deploymentsRule = map[string]interface{}{
+	"apiGroups": []interface{}{"apps"},
+	"resources": []interface{}{"deployments"},
+	"verbs":     []interface{}{"create", "delete", "get", "list", "patch", "update", "watch"},
+}
+
+// addManagerRule adds the rule to config/rbac/role.yaml unless an identical rule exists.
+// Used by the Helm and Ansible samples, whose RBAC is not generated from markers.
+func (ms *MemcachedSample) addManagerRule(rule map[string]interface{}) error {
+	role, err := mutate.LoadYAMLFile(filepath.Join(ms.dir(), "config", "rbac", "role.yaml"))
+	if err != nil {
+		return err
+	}
+
+	rules, err := role.Get("rules")
+	if err == nil {
+		existing, _ := rules.([]interface{})
+		for _, r := range existing {
+			if reflect.DeepEqual(r, rule) {
+				return nil
+			}
+		}
+	}
+
+	if err := role.Append(rule, "rules"); err != nil {
+		return fmt.Errorf("encountered an error adding a rule to the manager role: %w", err)
+	}
+
+	return role.Save()
+}
+
+// writeFiles writes the files, keyed by path, creating their directories
+func writeFiles(files map[string]string) error {
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("encountered an error creating the directory of %q: %w", path, err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("encountered an error writing %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package samples
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// CustomResourceSamplePath returns the path to the custom resource sample
+// (config/samples/<group>_<version>_<kind>.yaml) scaffolded for the Sample
+func CustomResourceSamplePath(sample Sample) string {
+	return filepath.Join(sample.CommandContext().Dir(),
+		sample.Name(),
+		"config",
+		"samples",
+		fmt.Sprintf("%s_%s_%s.yaml", sample.GVK().Group, sample.GVK().Version, strings.ToLower(sample.GVK().Kind)))
+}
+
+// CustomResource is an editable custom resource, typically loaded
+// from the custom resource sample scaffolded for a Sample
+type CustomResource struct {
+	obj *unstructured.Unstructured
+}
+
+// LoadCustomResource loads the custom resource sample scaffolded for the Sample
+func LoadCustomResource(sample Sample) (*CustomResource, error) {
+	path := CustomResourceSamplePath(sample)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading custom resource sample %q: %w", path, err)
+	}
+
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding custom resource sample %q: %w", path, err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(j); err != nil {
+		return nil, fmt.Errorf("encountered an error when decoding custom resource sample %q: %w", path, err)
+	}
+
+	return &CustomResource{obj: obj}, nil
+}
+
+// NewCustomResource creates a CustomResource from an unstructured object
+func NewCustomResource(obj *unstructured.Unstructured) *CustomResource {
+	return &CustomResource{obj: obj}
+}
+
+// Object returns the underlying unstructured object
+func (cr *CustomResource) Object() *unstructured.Unstructured {
+	return cr.obj
+}
+
+// DeepCopy returns a copy of the CustomResource that can be edited independently
+func (cr *CustomResource) DeepCopy() *CustomResource {
+	return &CustomResource{obj: cr.obj.DeepCopy()}
+}
+
+func (cr *CustomResource) Name() string {
+	return cr.obj.GetName()
+}
+
+func (cr *CustomResource) Namespace() string {
+	return cr.obj.GetNamespace()
+}
+
+// Resource returns the fully qualified resource type of the
+// CustomResource (i.e memcached.cache.example.com) for use with kubectl
+func (cr *CustomResource) Resource() string {
+	gvk := cr.obj.GroupVersionKind()
+	if gvk.Group == "" {
+		return strings.ToLower(gvk.Kind)
+	}
+
+	return strings.ToLower(gvk.Kind) + "." + gvk.Group
+}
+
+// SetName sets metadata.name
+func (cr *CustomResource) SetName(name string) *CustomResource {
+	cr.obj.SetName(name)
+	return cr
+}
+
+// SetNamespace sets metadata.namespace. When empty the namespace of the Kubectl is used.
+func (cr *CustomResource) SetNamespace(namespace string) *CustomResource {
+	cr.obj.SetNamespace(namespace)
+	return cr
+}
+
+// SetSpecField sets the field at the path under spec, i.e SetSpecField(3, "size")
+func (cr *CustomResource) SetSpecField(value interface{}, fields ...string) error {
+	return cr.SetField(value, append([]string{"spec"}, fields...)...)
+}
+
+// RemoveSpecField removes the field at the path under spec
+func (cr *CustomResource) RemoveSpecField(fields ...string) {
+	unstructured.RemoveNestedField(cr.obj.Object, append([]string{"spec"}, fields...)...)
+}
+
+// SetField sets the field at the path from the root of the object
+func (cr *CustomResource) SetField(value interface{}, fields ...string) error {
+	normalized, err := normalizeValue(value)
+	if err != nil {
+		return fmt.Errorf("encountered an error when setting field %q: %w", strings.Join(fields, "."), err)
+	}
+
+	err = unstructured.SetNestedField(cr.obj.Object, normalized, fields...)
+	if err != nil {
+		return fmt.Errorf("encountered an error when setting field %q: %w", strings.Join(fields, "."), err)
+	}
+
+	return nil
+}
+
+// Instances returns n copies of the CustomResource named <name>-<index>
+func (cr *CustomResource) Instances(n int) []*CustomResource {
+	instances := make([]*CustomResource, 0, n)
+	for i := 0; i < n; i++ {
+		instance := cr.DeepCopy()
+		instance.SetName(fmt.Sprintf("%s-%d", cr.Name(), i))
+		instances = append(instances, instance)
+	}
+
+	return instances
+}
+
+// YAML returns the CustomResource encoded as YAML
+func (cr *CustomResource) YAML() ([]byte, error) {
+	b, err := yaml.Marshal(cr.obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when encoding the custom resource: %w", err)
+	}
+
+	return b, nil
+}
+
+// normalizeValue converts a value into one that can be stored in an unstructured object
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case float32:
+		return float64(v), nil
+	case []string:
+		s := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			s = append(s, e)
+		}
+		return s, nil
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return m, nil
+	case nil, bool, int64, float64, string, []interface{}, map[string]interface{}:
+		return v, nil
+	default:
+		// Round trip anything else (i.e structs) through its JSON representation
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var out interface{}
+		if err := yaml.Unmarshal(b, &out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+}
@@ -162,7 +162,7 @@ func (gs *GenericSample) GenerateInit() error {
 
 	output, err := gs.commandContext.Run(ex, gs.name)
 	if err != nil {
-		return fmt.Errorf("error running command: ", err, "output:", string(output))
+		return fmt.Errorf("error running command: %w, output: %s", err, string(output))
 	}
 
 	return nil
@@ -188,7 +188,7 @@ func (gs *GenericSample) GenerateApi() error {
 
 	output, err := gs.commandContext.Run(ex, gs.name)
 	if err != nil {
-		return fmt.Errorf("error running command: ", err, "output:", string(output))
+		return fmt.Errorf("error running command: %w, output: %s", err, string(output))
 	}
 
 	return nil
@@ -214,7 +214,7 @@ func (gs *GenericSample) GenerateWebhook() error {
 
 	output, err := gs.commandContext.Run(ex, gs.name)
 	if err != nil {
-		return fmt.Errorf("error running command: ", err, "output:", string(output))
+		return fmt.Errorf("error running command: %w, output: %s", err, string(output))
 	}
 
 	return nil
@@ -0,0 +1,216 @@
+package samples
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Check is a verification step run against a scaffolded Sample by Verify
+type Check struct {
+	Name string
+	// Run returns the output of the check and an error if it failed
+	Run func(sample Sample) ([]byte, error)
+}
+
+// CheckResult is the outcome of running a Check
+type CheckResult struct {
+	Name     string
+	Output   string
+	Duration time.Duration
+	Err      error
+}
+
+// VerifyReport is the outcome of every Check run by Verify, in the order they ran
+type VerifyReport []CheckResult
+
+// Failed returns the results of the checks that failed
+func (vr VerifyReport) Failed() VerifyReport {
+	var failed VerifyReport
+	for _, result := range vr {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing every failed check and its output, if any check failed
+func (vr VerifyReport) Err() error {
+	failed := vr.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	for _, result := range failed {
+		fmt.Fprintf(&b, "\n%s failed after %s: %v\n%s", result.Name, result.Duration.Round(time.Millisecond), result.Err, result.Output)
+	}
+
+	return fmt.Errorf("%d of %d checks failed:%s", len(failed), len(vr), b.String())
+}
+
+// String summarizes the result and duration of each check
+func (vr VerifyReport) String() string {
+	var b strings.Builder
+	for _, result := range vr {
+		status := "ok"
+		if result.Err != nil {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%-4s %s (%s)\n", status, result.Name, result.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// Verify runs the checks against the Sample, or DefaultChecks if none are given. Every
+// check is run even if an earlier one fails, use VerifyReport.Err to get the failures.
+func Verify(sample Sample, checks ...Check) VerifyReport {
+	if len(checks) == 0 {
+		checks = DefaultChecks()
+	}
+
+	report := make(VerifyReport, 0, len(checks))
+	for _, check := range checks {
+		start := time.Now()
+		out, err := check.Run(sample)
+		report = append(report, CheckResult{
+			Name:     check.Name,
+			Output:   string(out),
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+
+	return report
+}
+
+// DefaultChecks returns the checks for a Go project: GoBuild, GoVet, ManifestsUpToDate and MakeTest
+func DefaultChecks() []Check {
+	return []Check{GoBuild(), GoVet(), ManifestsUpToDate(), MakeTest()}
+}
+
+// CommandCheck runs the command in the Sample directory and fails if it exits with an error
+func CommandCheck(name string, command string, args ...string) Check {
+	return Check{
+		Name: name,
+		Run: func(sample Sample) ([]byte, error) {
+			return sample.CommandContext().Run(exec.Command(command, args...), sample.Name())
+		},
+	}
+}
+
+// GoBuild checks that `go build ./...` succeeds
+func GoBuild() Check {
+	return CommandCheck("go build", "go", "build", "./...")
+}
+
+// GoVet checks that `go vet ./...` reports no problems
+func GoVet() Check {
+	return CommandCheck("go vet", "go", "vet", "./...")
+}
+
+// MakeTest checks that `make test`, which runs the envtest based tests of the scaffold, passes
+func MakeTest() Check {
+	return CommandCheck("make test", "make", "test")
+}
+
+// ManifestsUpToDate checks that `make manifests generate` does not change any file,
+// i.e that the generated CRDs, RBAC and deepcopy code match the API and markers
+func ManifestsUpToDate() Check {
+	return Check{
+		Name: "make manifests generate",
+		Run: func(sample Sample) ([]byte, error) {
+			dir := sampleDir(sample)
+
+			before, err := hashFiles(dir)
+			if err != nil {
+				return nil, err
+			}
+
+			out, err := sample.CommandContext().Run(exec.Command("make", "manifests", "generate"), sample.Name())
+			if err != nil {
+				return out, err
+			}
+
+			after, err := hashFiles(dir)
+			if err != nil {
+				return out, err
+			}
+
+			if changed := changedFiles(before, after); len(changed) > 0 {
+				return out, fmt.Errorf("the generated files are out of date, changed: %s", strings.Join(changed, ", "))
+			}
+
+			return out, nil
+		},
+	}
+}
+
+// ignoredDirs are not compared by ManifestsUpToDate, they hold tools and build output
+var ignoredDirs = map[string]bool{
+	".git":    true,
+	"bin":     true,
+	"testbin": true,
+	"vendor":  true,
+}
+
+// hashFiles returns the checksum of every file in the directory, keyed by relative path
+func hashFiles(dir string) (map[string][sha256.Size]byte, error) {
+	hashes := make(map[string][sha256.Size]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && ignoredDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hashes[rel] = sha256.Sum256(b)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encountered an error when reading the files of %q: %w", dir, err)
+	}
+
+	return hashes, nil
+}
+
+// changedFiles returns the sorted paths that were added, removed or modified
+func changedFiles(before map[string][sha256.Size]byte, after map[string][sha256.Size]byte) []string {
+	var changed []string
+	for path, hash := range after {
+		if old, ok := before[path]; !ok || old != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
@@ -0,0 +1,76 @@
+package samples
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeVerifyTestFiles(t, dir, map[string]string{
+		"Makefile": "all: build\n",
+		filepath.Join("config", "crd", "bases", "cache.example.com_memcacheds.yaml"): "kind: CustomResourceDefinition\n",
+		filepath.Join("config", "rbac", "role.yaml"):                                 "kind: ClusterRole\n",
+		filepath.Join("bin", "controller-gen"):                                       "binary",
+		filepath.Join(".git", "HEAD"):                                                "ref: refs/heads/main\n",
+	})
+
+	before, err := hashFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for path := range before {
+		if path == filepath.Join("bin", "controller-gen") || path == filepath.Join(".git", "HEAD") {
+			t.Errorf("expected %s in an ignored directory not to be hashed", path)
+		}
+	}
+
+	// Unchanged files are not reported
+	after, err := hashFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed := changedFiles(before, after); len(changed) != 0 {
+		t.Errorf("got %v, want no changed files", changed)
+	}
+
+	writeVerifyTestFiles(t, dir, map[string]string{
+		filepath.Join("config", "rbac", "role.yaml"):         "kind: ClusterRole\nrules: []\n",
+		filepath.Join("config", "samples", "memcached.yaml"): "kind: Memcached\n",
+		filepath.Join("bin", "controller-gen"):               "rebuilt binary",
+		filepath.Join(".git", "HEAD"):                        "ref: refs/heads/other\n",
+	})
+	if err := os.Remove(filepath.Join(dir, "Makefile")); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err = hashFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"Makefile",
+		filepath.Join("config", "rbac", "role.yaml"),
+		filepath.Join("config", "samples", "memcached.yaml"),
+	}
+	if changed := changedFiles(before, after); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("got %v, want %v", changed, expected)
+	}
+}
+
+func writeVerifyTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}